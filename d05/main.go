@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"slices"
@@ -11,7 +12,14 @@ import (
 	"strings"
 )
 
-var debug = flag.Bool("debug", false, "debug mode")
+var (
+	debug   = flag.Bool("debug", false, "debug mode")
+	dot     = flag.Bool("dot", false, "write the rule graph in DOT format")
+	dotOnly = flag.Int("dot-update", -1, "restrict -dot to the pages of the update at this index")
+	explain = flag.Bool("explain", false, "explain every rule each update violates")
+	lint    = flag.Bool("lint", false, "report duplicate, contradictory and implied rules, to stderr with -dot")
+	repl    = flag.Bool("repl", false, "interactively add rules and check updates, starting from the file's rules")
+)
 
 func get[T any](v T, err error) T {
 	if err != nil {
@@ -20,6 +28,13 @@ func get[T any](v T, err error) T {
 	return v
 }
 
+func get2[T, U any](t T, u U, err error) (T, U) {
+	if err != nil {
+		log.Fatal(err)
+	}
+	return t, u
+}
+
 type invalid struct {
 	i, j  int // indexes of conflict found
 	rule  *rule
@@ -50,23 +65,110 @@ func (r *rule) String() string {
 }
 
 func (r *rule) addAfter(ra *rule) {
-	if _, ok := r.after[ra.page]; ok {
-		log.Fatalf("redundant rule %d|%d", r.page, ra.page)
-	} else {
-		r.after[ra.page] = ra
-	}
+	r.after[ra.page] = ra
 }
 
 func (r *rule) addBefore(rb *rule) {
-	if _, ok := r.before[rb.page]; ok {
-		log.Fatalf("redundant rule %d|%d", rb.page, r.page)
-	} else {
-		r.before[rb.page] = rb
-	}
+	r.before[rb.page] = rb
 }
 
 type rules struct {
-	rs map[int]*rule
+	rs    map[int]*rule
+	lines map[[2]int][]int // before|after -> lines the rule appears on
+}
+
+// add records the rule before|after from the given line of the input.
+// Duplicate rules are kept only once, but every line is remembered for lint.
+func (rs *rules) add(before, after, line int) {
+	rb := rs.get(before)
+	ra := rs.get(after)
+
+	rb.addAfter(ra)
+	ra.addBefore(rb)
+
+	key := [2]int{before, after}
+	rs.lines[key] = append(rs.lines[key], line)
+}
+
+type problem struct {
+	line int
+	msg  string
+}
+
+func (p problem) String() string {
+	return fmt.Sprintf("line %d: %s", p.line, p.msg)
+}
+
+// lint finds duplicate rules, pairs of rules which directly contradict each
+// other, and rules which are already implied by a chain of other rules.
+func (rs *rules) lint() []problem {
+	var ret []problem
+	for key, lines := range rs.lines {
+		before, after := key[0], key[1]
+
+		for _, line := range lines[1:] {
+			ret = append(ret, problem{line, fmt.Sprintf("duplicate rule %d|%d (first on line %d)", before, after, lines[0])})
+		}
+
+		if other, ok := rs.lines[[2]int{after, before}]; ok && other[0] < lines[0] {
+			ret = append(ret, problem{lines[0], fmt.Sprintf("rule %d|%d contradicts %d|%d on line %d", before, after, after, before, other[0])})
+		}
+
+		if path := rs.chain(before, after); path != nil {
+			var pages []string
+			for _, p := range path {
+				pages = append(pages, strconv.Itoa(p))
+			}
+			ret = append(ret, problem{lines[0], fmt.Sprintf("rule %d|%d is implied by %s", before, after, strings.Join(pages, "|"))})
+		}
+	}
+
+	slices.SortFunc(ret, func(a, b problem) int {
+		if a.line != b.line {
+			return a.line - b.line
+		}
+		return strings.Compare(a.msg, b.msg)
+	})
+	return ret
+}
+
+// chain returns the shortest path of rules from before to after which doesn't
+// use the direct rule before|after, or nil if there is none.
+func (rs *rules) chain(before, after int) []int {
+	parent := map[int]int{before: before}
+	queue := []int{before}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+
+		var afters []int
+		for a := range rs.rs[p].after {
+			afters = append(afters, a)
+		}
+		slices.Sort(afters)
+
+		for _, a := range afters {
+			if p == before && a == after {
+				continue
+			}
+			if _, ok := parent[a]; ok {
+				continue
+			}
+			parent[a] = p
+
+			if a == after {
+				path := []int{after}
+				for p := after; p != before; {
+					p = parent[p]
+					path = append(path, p)
+				}
+				slices.Reverse(path)
+				return path
+			}
+			queue = append(queue, a)
+		}
+	}
+	return nil
 }
 
 func (rs *rules) get(page int) *rule {
@@ -109,40 +211,244 @@ func (rs *rules) findInvalid(u update) *invalid {
 	return nil
 }
 
-func (rs *rules) autocorrect(u update) update {
-	// Sort the update pages into most-constrained first order
-	mcf := make(update, len(u))
-	copy(mcf, u)
-	slices.SortFunc(mcf, func(a, b int) int {
-		ra, aok := rs.rs[a]
-		rb, bok := rs.rs[b]
+// violations returns every rule which u breaks, in the order the pages appear.
+func (rs *rules) violations(u update) []*invalid {
+	var ret []*invalid
+	for i, p := range u {
+		r, ok := rs.rs[p]
+		if !ok {
+			continue
+		}
+		for j, pp := range u[i:] {
+			if cause, ok := r.before[pp]; ok {
+				ret = append(ret, &invalid{
+					i:     i,
+					j:     j + i,
+					rule:  r,
+					cause: cause,
+				})
+			}
+		}
+	}
+	return ret
+}
 
-		if !aok && !bok {
-			return 0
+// moves returns the smallest number of pages which must be moved to make u
+// valid. The pages which stay put keep their order, so no two of them can be
+// the wrong way round under the rules between the pages of u, followed
+// transitively. Being the wrong way round is itself a partial order, so by
+// Dilworth's theorem the most pages which can stay is len(u) less a maximum
+// matching between pages and the ones they're wrongly after, leaving the size
+// of the matching to move.
+func (rs *rules) moves(u update) (int, error) {
+	if _, err := rs.autocorrect(u); err != nil {
+		return 0, err
+	}
+
+	// before[i][j] is set if the rules put u[i] before u[j], directly or
+	// through other pages of u
+	n := len(u)
+	before := make([][]bool, n)
+	for i, p := range u {
+		before[i] = make([]bool, n)
+		r, ok := rs.rs[p]
+		if !ok {
+			continue
 		}
-		if !aok {
-			return 1
+		for j, pp := range u {
+			if _, ok := r.after[pp]; ok && i != j {
+				before[i][j] = true
+			}
 		}
-		if !bok {
-			return -1
+	}
+	for k := range n {
+		for i := range n {
+			if !before[i][k] {
+				continue
+			}
+			for j := range n {
+				if before[k][j] {
+					before[i][j] = true
+				}
+			}
 		}
+	}
 
-		return len(rb.after) - len(ra.after)
-	})
+	// Kuhn's algorithm: match[j] is the index matched with j, which comes
+	// after it in u but must be before it, or -1
+	match := make([]int, n)
+	for j := range match {
+		match[j] = -1
+	}
+	var augment func(i int, seen []bool) bool
+	augment = func(i int, seen []bool) bool {
+		for j := range i {
+			if !before[i][j] || seen[j] {
+				continue
+			}
+			seen[j] = true
+			if match[j] < 0 || augment(match[j], seen) {
+				match[j] = i
+				return true
+			}
+		}
+		return false
+	}
+
+	moves := 0
+	for i := range n {
+		if augment(i, make([]bool, n)) {
+			moves++
+		}
+	}
+	return moves, nil
+}
 
-	ret := make(update, len(mcf))
-	copy(ret, mcf)
+// autocorrect reorders the pages of u so that every rule between them holds.
+// It topologically sorts the rules restricted to the pages in the update,
+// breaking ties by each page's original position so the result is
+// deterministic. If those rules contain a cycle, no valid order exists and an
+// error naming the cycle is returned.
+func (rs *rules) autocorrect(u update) (update, error) {
+	// Build the induced subgraph over the update's indexes: an edge i->j
+	// means page u[i] must come before page u[j].
+	succ := make([][]int, len(u))
+	indegree := make([]int, len(u))
+	for i, p := range u {
+		r, ok := rs.rs[p]
+		if !ok {
+			continue
+		}
+		for j, pp := range u {
+			if _, ok := r.after[pp]; ok && i != j {
+				succ[i] = append(succ[i], j)
+				indegree[j]++
+			}
+		}
+	}
 
-	for !rs.valid(ret) {
-		invalid := rs.findInvalid(ret)
+	// Kahn's algorithm, always taking the earliest ready index
+	ret := make(update, 0, len(u))
+	done := make([]bool, len(u))
+	for len(ret) < len(u) {
+		next := -1
+		for i := range u {
+			if !done[i] && indegree[i] == 0 {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			return nil, fmt.Errorf("no valid order: %s", rs.cycle(u, done))
+		}
+
+		done[next] = true
+		ret = append(ret, u[next])
+		for _, j := range succ[next] {
+			indegree[j]--
+		}
 		if *debug {
-			fmt.Printf("invalid: %s\n", ret)
-			fmt.Printf("invalid at %d and %d: %s\n", invalid.i, invalid.j, invalid)
+			fmt.Printf("autocorrect: %s\n", ret)
 		}
-		ret[invalid.i], ret[invalid.j] = ret[invalid.j], ret[invalid.i]
 	}
 
-	return ret
+	return ret, nil
+}
+
+// cycle describes a cycle of rules among the pages of u which are not done.
+// Every remaining page has a remaining page which must come before it, so
+// walking backwards from any of them must eventually revisit a page.
+func (rs *rules) cycle(u update, done []bool) string {
+	remaining := make(map[int]bool)
+	for i, p := range u {
+		if !done[i] {
+			remaining[p] = true
+		}
+	}
+
+	var walk []int
+	seen := make(map[int]int) // page -> index in walk
+	p := -1
+	for i := range u {
+		if !done[i] {
+			p = u[i]
+			break
+		}
+	}
+	for {
+		if at, ok := seen[p]; ok {
+			walk = walk[at:]
+			break
+		}
+		seen[p] = len(walk)
+		walk = append(walk, p)
+
+		var befores []int
+		for b := range rs.rs[p].before {
+			if remaining[b] {
+				befores = append(befores, b)
+			}
+		}
+		p = slices.Min(befores)
+	}
+
+	// walk follows the rules backwards, so reverse it to read in order
+	slices.Reverse(walk)
+	var pages []string
+	for _, p := range walk {
+		pages = append(pages, strconv.Itoa(p))
+	}
+	pages = append(pages, pages[0])
+	return "cycle " + strings.Join(pages, "|")
+}
+
+// writeDot writes the rule graph to w in graphviz DOT format. If u is not nil,
+// only the pages in u and the rules between them are included, and any rule
+// which u violates is drawn in red.
+func (rs *rules) writeDot(w io.Writer, u update) error {
+	var pages []int
+	positions := make(map[int]int) // page -> index in u
+	if u == nil {
+		for p := range rs.rs {
+			pages = append(pages, p)
+		}
+	} else {
+		for i, p := range u {
+			positions[p] = i
+			pages = append(pages, p)
+		}
+	}
+	slices.Sort(pages)
+
+	var b strings.Builder
+	b.WriteString("digraph rules {\n")
+	for _, p := range pages {
+		b.WriteString(fmt.Sprintf("\t%d;\n", p))
+	}
+	for _, p := range pages {
+		r, ok := rs.rs[p]
+		if !ok {
+			continue
+		}
+		var afters []int
+		for a := range r.after {
+			if _, ok := positions[a]; ok || u == nil {
+				afters = append(afters, a)
+			}
+		}
+		slices.Sort(afters)
+		for _, a := range afters {
+			if u != nil && positions[a] < positions[p] {
+				b.WriteString(fmt.Sprintf("\t%d -> %d [color=red];\n", p, a))
+			} else {
+				b.WriteString(fmt.Sprintf("\t%d -> %d;\n", p, a))
+			}
+		}
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
 }
 
 type update []int
@@ -161,6 +467,35 @@ func (u update) middle() int {
 	return u[len(u)/2]
 }
 
+func parseRule(line string) (int, int, error) {
+	pages := strings.Split(line, "|")
+	if len(pages) != 2 {
+		return 0, 0, fmt.Errorf("invalid rule %q", line)
+	}
+
+	before, err := strconv.Atoi(pages[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	after, err := strconv.Atoi(pages[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return before, after, nil
+}
+
+func parseUpdate(line string) (update, error) {
+	var u update
+	for _, page := range strings.Split(line, ",") {
+		p, err := strconv.Atoi(page)
+		if err != nil {
+			return nil, err
+		}
+		u = append(u, p)
+	}
+	return u, nil
+}
+
 func main() {
 	flag.Parse()
 
@@ -172,15 +507,16 @@ func main() {
 	f := get(os.Open(filename))
 	defer f.Close()
 
-	rules := &rules{
-		rs: make(map[int]*rule),
-	}
+	set := NewRuleSet()
+	rules := set.rules
 	updates := make([]update, 0)
 
 	section := "rules"
+	lineno := 0
 	s := bufio.NewScanner(f)
 	for s.Scan() {
 		line := s.Text()
+		lineno++
 
 		switch section {
 		case "rules":
@@ -189,28 +525,66 @@ func main() {
 				continue
 			}
 
-			pages := strings.Split(line, "|")
-			if len(pages) != 2 {
-				log.Fatalf("invalid rule %q", line)
-			}
+			before, after := get2(parseRule(line))
+			rules.add(before, after, lineno)
 
-			before := get(strconv.Atoi(pages[0]))
-			after := get(strconv.Atoi(pages[1]))
+		case "updates":
+			updates = append(updates, get(parseUpdate(line)))
+		}
+	}
 
-			rb := rules.get(before)
-			ra := rules.get(after)
+	if *repl {
+		set.added = lineno
+		if err := set.repl(os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-			rb.addAfter(ra)
-			ra.addBefore(rb)
+	if *lint {
+		// Keep the DOT output on stdout parseable
+		w := io.Writer(os.Stdout)
+		if *dot {
+			w = os.Stderr
+		}
+		for _, p := range rules.lint() {
+			fmt.Fprintln(w, p)
+		}
+	}
 
-		case "updates":
-			pages := strings.Split(line, ",")
-			var u update
-			for _, page := range pages {
-				u = append(u, get(strconv.Atoi(page)))
+	if *dot {
+		var u update
+		if *dotOnly >= 0 {
+			if *dotOnly >= len(updates) {
+				log.Fatalf("no update at index %d", *dotOnly)
 			}
-			updates = append(updates, u)
+			u = updates[*dotOnly]
+		}
+		if err := rules.writeDot(os.Stdout, u); err != nil {
+			log.Fatal(err)
 		}
+		return
+	}
+
+	if *explain {
+		for _, u := range updates {
+			vs := rules.violations(u)
+			if len(vs) == 0 {
+				fmt.Printf("%s: valid\n", u)
+				continue
+			}
+
+			moves, err := rules.moves(u)
+			if err != nil {
+				fmt.Printf("%s: invalid (violations: %d, %s)\n", u, len(vs), err)
+			} else {
+				fmt.Printf("%s: invalid (violations: %d, pages to move: %d)\n", u, len(vs), moves)
+			}
+			for _, v := range vs {
+				fmt.Printf("  %s\n", v)
+			}
+		}
+		return
 	}
 
 	if *debug {
@@ -244,7 +618,11 @@ func main() {
 
 	correctedMiddles := 0
 	for _, u := range invalids {
-		corrected := rules.autocorrect(u)
+		corrected, err := rules.autocorrect(u)
+		if err != nil {
+			fmt.Printf("cannot autocorrect %s: %s\n", u, err)
+			os.Exit(1)
+		}
 		if *debug {
 			fmt.Printf("%s: %v\n", corrected, rules.valid(corrected))
 		}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newRules returns rules from before|after pairs, as if each were on its own
+// line.
+func newRules(pairs [][2]int) *rules {
+	rs := &rules{
+		rs:    make(map[int]*rule),
+		lines: make(map[[2]int][]int),
+	}
+	for i, p := range pairs {
+		rs.add(p[0], p[1], i+1)
+	}
+	return rs
+}
+
+func TestRules_autocorrect(t *testing.T) {
+	testcases := []struct {
+		name  string
+		rules [][2]int
+		u     update
+		want  update
+	}{
+		{"valid", [][2]int{{1, 2}, {2, 3}}, update{1, 2, 3}, update{1, 2, 3}},
+		{"reversed", [][2]int{{1, 2}, {2, 3}}, update{3, 2, 1}, update{1, 2, 3}},
+		{"ties keep their order", [][2]int{{1, 2}}, update{4, 3, 2, 1}, update{4, 3, 1, 2}},
+		{"unrelated", [][2]int{{1, 2}}, update{5, 4}, update{5, 4}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			rs := newRules(tc.rules)
+			// Rules are held in maps, so make sure their order doesn't leak out
+			for range 20 {
+				got, err := rs.autocorrect(tc.u)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !slices.Equal(got, tc.want) {
+					t.Fatalf("wrong result. got = %s, want = %s", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRules_autocorrectCycle(t *testing.T) {
+	testcases := []struct {
+		name  string
+		rules [][2]int
+		u     update
+	}{
+		{"three", [][2]int{{1, 2}, {2, 3}, {3, 1}}, update{3, 2, 1}},
+		{"two", [][2]int{{1, 2}, {2, 1}}, update{1, 2}},
+		{"cycle after valid pages", [][2]int{{4, 1}, {1, 2}, {2, 3}, {3, 2}}, update{3, 2, 1, 4}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			rs := newRules(tc.rules)
+			_, err := rs.autocorrect(tc.u)
+			if err == nil {
+				t.Fatal("wrong result. got = nil, want error")
+			}
+
+			_, cycle, ok := strings.Cut(err.Error(), "cycle ")
+			if !ok {
+				t.Fatalf("error doesn't name a cycle: %s", err)
+			}
+			pages := strings.Split(cycle, "|")
+			if len(pages) < 3 || pages[0] != pages[len(pages)-1] {
+				t.Fatalf("not a cycle: %s", cycle)
+			}
+			for i := range pages[1:] {
+				before, _ := strconv.Atoi(pages[i])
+				after, _ := strconv.Atoi(pages[i+1])
+				if !slices.Contains(tc.rules, [2]int{before, after}) {
+					t.Errorf("no rule %d|%d in cycle %s", before, after, cycle)
+				}
+			}
+		})
+	}
+}
+
+func TestRules_moves(t *testing.T) {
+	testcases := []struct {
+		name  string
+		rules [][2]int
+		u     update
+		want  int
+	}{
+		{"valid", [][2]int{{1, 2}, {2, 3}}, update{1, 2, 3}, 0},
+		{"reversed", [][2]int{{1, 2}, {2, 3}}, update{3, 2, 1}, 2},
+		{"one late", [][2]int{{1, 2}, {1, 3}, {2, 3}}, update{2, 3, 1}, 1},
+		{"partial order", [][2]int{{1, 4}, {5, 2}, {3, 2}, {1, 3}}, update{3, 5, 2, 1, 4}, 1},
+		{"transitive", [][2]int{{1, 2}, {2, 3}}, update{3, 1, 2}, 1},
+		{"missing page", [][2]int{{1, 2}, {2, 3}}, update{3, 1}, 0},
+		{"unrelated", [][2]int{{1, 2}}, update{4, 3, 2, 1}, 1},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := newRules(tc.rules).moves(tc.u)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := got, tc.want; got != want {
+				t.Errorf("wrong result. got = %d, want = %d", got, want)
+			}
+		})
+	}
+}
+
+func TestRules_lint(t *testing.T) {
+	testcases := []struct {
+		name  string
+		rules [][2]int
+		want  []string
+	}{
+		{"clean", [][2]int{{1, 2}, {2, 3}}, nil},
+		{"duplicate", [][2]int{{1, 2}, {2, 3}, {1, 2}}, []string{
+			"line 3: duplicate rule 1|2 (first on line 1)",
+		}},
+		{"contradiction", [][2]int{{1, 2}, {3, 4}, {2, 1}}, []string{
+			"line 3: rule 2|1 contradicts 1|2 on line 1",
+		}},
+		{"implied", [][2]int{{1, 3}, {1, 2}, {2, 3}}, []string{
+			"line 1: rule 1|3 is implied by 1|2|3",
+		}},
+		{"all", [][2]int{{1, 2}, {2, 3}, {3, 1}, {1, 2}, {1, 3}}, []string{
+			"line 4: duplicate rule 1|2 (first on line 1)",
+			"line 5: rule 1|3 contradicts 3|1 on line 3",
+			"line 5: rule 1|3 is implied by 1|2|3",
+		}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, p := range newRules(tc.rules).lint() {
+				got = append(got, p.String())
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("wrong result. got = %q, want = %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRuleSet(t *testing.T) {
+	s := NewRuleSet()
+	check := func(u, want update, violations int) {
+		t.Helper()
+		if got := len(s.Validate(u)); got != violations {
+			t.Errorf("%s: wrong violations. got = %d, want = %d", u, got, violations)
+		}
+		got, err := s.Fix(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: wrong result. got = %s, want = %s", u, got, want)
+		}
+	}
+	pages := func() []int {
+		var ret []int
+		for p := range s.rules.rs {
+			ret = append(ret, p)
+		}
+		slices.Sort(ret)
+		return ret
+	}
+
+	s.AddRule(1, 2)
+	check(update{2, 1, 3}, update{1, 2, 3}, 1)
+	s.AddRule(2, 3)
+	check(update{3, 2, 1}, update{1, 2, 3}, 2)
+	s.AddRule(3, 1)
+	if _, err := s.Fix(update{1, 2, 3}); err == nil {
+		t.Error("wrong result. got = nil, want cycle error")
+	}
+
+	if !s.RemoveRule(1, 2) {
+		t.Error("wrong result. got = false, want = true removing 1|2")
+	}
+	if s.RemoveRule(1, 2) {
+		t.Error("wrong result. got = true, want = false removing 1|2 twice")
+	}
+	check(update{1, 2, 3}, update{2, 3, 1}, 1)
+	if got, want := pages(), []int{1, 2, 3}; !slices.Equal(got, want) {
+		t.Errorf("wrong pages. got = %v, want = %v", got, want)
+	}
+
+	s.RemoveRule(3, 1)
+	check(update{3, 2, 1}, update{2, 3, 1}, 1)
+	if got, want := pages(), []int{2, 3}; !slices.Equal(got, want) {
+		t.Errorf("wrong pages. got = %v, want = %v", got, want)
+	}
+
+	s.RemoveRule(2, 3)
+	check(update{3, 2}, update{3, 2}, 0)
+	if got := pages(); len(got) != 0 {
+		t.Errorf("wrong pages. got = %v, want none", got)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RuleSet is a set of page ordering rules which can be changed between
+// checking updates. The before and after indexes of each page are kept up to
+// date as rules are added and removed, so nothing is rebuilt per update.
+type RuleSet struct {
+	rules *rules
+	added int // rules added so far, standing in for input line numbers
+}
+
+func NewRuleSet() *RuleSet {
+	return &RuleSet{
+		rules: &rules{
+			rs:    make(map[int]*rule),
+			lines: make(map[[2]int][]int),
+		},
+	}
+}
+
+// AddRule adds the rule that page before must come before page after.
+func (s *RuleSet) AddRule(before, after int) {
+	s.added++
+	s.rules.add(before, after, s.added)
+}
+
+// RemoveRule removes the rule before|after, reporting whether it existed.
+func (s *RuleSet) RemoveRule(before, after int) bool {
+	return s.rules.remove(before, after)
+}
+
+// Validate returns every rule which u breaks, or nil if u is valid.
+func (s *RuleSet) Validate(u update) []*invalid {
+	return s.rules.violations(u)
+}
+
+// Fix returns u reordered so that it is valid.
+func (s *RuleSet) Fix(u update) (update, error) {
+	return s.rules.autocorrect(u)
+}
+
+// remove deletes the rule before|after and forgets pages with no rules left.
+func (rs *rules) remove(before, after int) bool {
+	key := [2]int{before, after}
+	if _, ok := rs.lines[key]; !ok {
+		return false
+	}
+	delete(rs.lines, key)
+
+	rb, ra := rs.rs[before], rs.rs[after]
+	delete(rb.after, after)
+	delete(ra.before, before)
+	for _, r := range []*rule{rb, ra} {
+		if len(r.after) == 0 && len(r.before) == 0 {
+			delete(rs.rs, r.page)
+		}
+	}
+	return true
+}
+
+// repl reads rules and updates from r a line at a time. A rule like 47|53 is
+// added, a rule prefixed with a minus like -47|53 is removed, and an update
+// like 75,47,61 is checked and fixed against the current rules.
+func (s *RuleSet) repl(r io.Reader, w io.Writer) error {
+	prompt := func() { fmt.Fprint(w, "> ") }
+
+	prompt()
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+
+		switch {
+		case line == "":
+
+		case strings.HasPrefix(line, "-"):
+			before, after, err := parseRule(line[1:])
+			if err != nil {
+				fmt.Fprintln(w, err)
+			} else if s.RemoveRule(before, after) {
+				fmt.Fprintf(w, "removed %d|%d\n", before, after)
+			} else {
+				fmt.Fprintf(w, "no rule %d|%d\n", before, after)
+			}
+
+		case strings.Contains(line, "|"):
+			before, after, err := parseRule(line)
+			if err != nil {
+				fmt.Fprintln(w, err)
+			} else {
+				s.AddRule(before, after)
+				fmt.Fprintf(w, "added %d|%d\n", before, after)
+			}
+
+		default:
+			u, err := parseUpdate(line)
+			if err != nil {
+				fmt.Fprintln(w, err)
+				break
+			}
+
+			vs := s.Validate(u)
+			if len(vs) == 0 {
+				fmt.Fprintln(w, "valid")
+				break
+			}
+			for _, v := range vs {
+				fmt.Fprintf(w, "invalid: %s\n", v)
+			}
+			if fixed, err := s.Fix(u); err != nil {
+				fmt.Fprintf(w, "cannot fix: %s\n", err)
+			} else {
+				fmt.Fprintf(w, "fixed: %s\n", fixed)
+			}
+		}
+
+		prompt()
+	}
+	fmt.Fprintln(w)
+	return sc.Err()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	tm "github.com/buger/goterm"
+)
+
+// LoopingObstruction is a new obstruction which traps the guard, along with
+// the loop it gets stuck in.
+type LoopingObstruction struct {
+	At    Pos
+	Cycle []Guard
+}
+
+// loopsFor simulates the guard once with each obstruction to find its loop.
+func loopsFor(grid *Grid, guard *Guard, obstructions []Pos) []LoopingObstruction {
+	ret := make([]LoopingObstruction, 0, len(obstructions))
+	for _, o := range obstructions {
+		grid.Set(o, newObstruction)
+		res := Simulate(grid, guard)
+		grid.Set(o, unvisited)
+		ret = append(ret, LoopingObstruction{At: o, Cycle: res.Cycle()})
+	}
+	return ret
+}
+
+type jsonStep struct {
+	X         int    `json:"x"`
+	Y         int    `json:"y"`
+	Direction string `json:"direction"`
+}
+
+type jsonObstruction struct {
+	X      int        `json:"x"`
+	Y      int        `json:"y"`
+	Length int        `json:"length"`
+	Loop   []jsonStep `json:"loop"`
+}
+
+func writeLoopsJSON(w io.Writer, loops []LoopingObstruction) error {
+	out := make([]jsonObstruction, 0, len(loops))
+	for _, l := range loops {
+		o := jsonObstruction{X: l.At.x, Y: l.At.y, Length: len(l.Cycle)}
+		for _, g := range l.Cycle {
+			o.Loop = append(o.Loop, jsonStep{g.p.x, g.p.y, string(g.c)})
+		}
+		out = append(out, o)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// browse shows each looping obstruction in turn with the loop it causes, drawn
+// with the guard's direction in each cell.
+// Enter or n moves to the next one, p to the previous one, and q quits.
+func browse(grid *Grid, loops []LoopingObstruction) {
+	if len(loops) == 0 {
+		fmt.Println("no looping obstructions")
+		return
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	for i := 0; i >= 0 && i < len(loops); {
+		l := loops[i]
+
+		grid.Reset()
+		grid.guard = nil
+		grid.Set(l.At, newObstruction)
+
+		tm.Clear()
+		grid.Render()
+		// Draw the loop as the guard facing each way it goes, the last one
+		// winning where it passes a cell more than once
+		for _, g := range l.Cycle {
+			tm.MoveCursor(g.p.x*2+1, g.p.y+1)
+			tm.Printf("%c", g.c)
+		}
+		tm.MoveCursor(1, grid.height+1)
+		tm.Printf("\nobstruction %d of %d at %d,%d: loop of %d steps\n", i+1, len(loops), l.At.x, l.At.y, len(l.Cycle))
+		tm.Printf("[enter/n]ext, [p]revious, [q]uit: ")
+		tm.Flush()
+
+		if !in.Scan() {
+			break
+		}
+		switch in.Text() {
+		case "q":
+			i = -1
+		case "p":
+			i = max(0, i-1)
+		default:
+			i++
+		}
+	}
+	grid.Reset()
+	fmt.Println()
+}
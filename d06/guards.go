@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TurnPolicy is how a guard turns when something is in its way.
+type TurnPolicy int
+
+const (
+	turnRight TurnPolicy = iota
+	turnLeft
+	turnReverse
+	turnAlternating // right, then left, then right...
+)
+
+func NewTurnPolicy(s string) (TurnPolicy, error) {
+	switch s {
+	case "right":
+		return turnRight, nil
+	case "left":
+		return turnLeft, nil
+	case "reverse":
+		return turnReverse, nil
+	case "alternating":
+		return turnAlternating, nil
+	default:
+		return turnRight, fmt.Errorf("unknown turn policy %q", s)
+	}
+}
+
+func (t TurnPolicy) String() string {
+	return [...]string{"right", "left", "reverse", "alternating"}[t]
+}
+
+func (d Direction) Cell() Cell {
+	return [...]Cell{guardUp, guardRight, guardDown, guardLeft}[d]
+}
+
+// Patroller is one of several guards on a grid, each with its own turn policy.
+type Patroller struct {
+	Guard
+	policy  TurnPolicy
+	turns   int // how many times the guard has turned
+	exited  bool
+	visited map[Pos]bool
+}
+
+func (p *Patroller) Turn() {
+	d := p.Direction()
+	switch p.policy {
+	case turnLeft:
+		d = (d + 3) % 4
+	case turnReverse:
+		d = (d + 2) % 4
+	case turnAlternating:
+		if p.turns%2 == 0 {
+			d = (d + 1) % 4
+		} else {
+			d = (d + 3) % 4
+		}
+	default:
+		d = (d + 1) % 4
+	}
+	p.c = d.Cell()
+	p.turns++
+}
+
+type PatrolResult struct {
+	Start   Guard
+	Policy  TurnPolicy
+	Visited int
+	Exited  bool // otherwise the guard loops forever
+}
+
+func (r PatrolResult) String() string {
+	outcome := "loops"
+	if r.Exited {
+		outcome = "exits"
+	}
+	return fmt.Sprintf("guard at %d,%d facing %c turning %s: visited %d, %s",
+		r.Start.p.x, r.Start.p.y, r.Start.c, r.Policy, r.Visited, outcome)
+}
+
+// patrolAll moves several guards around the grid at once. Each step, every
+// guard still on the grid takes a turn in order, treating the other guards as
+// obstructions. The guards are only stuck when all of them together are in a
+// state they've been in before, so that's what loop detection tracks.
+func patrolAll(grid *Grid, guards []*Guard, policies []TurnPolicy) []PatrolResult {
+	ps := make([]*Patroller, len(guards))
+	occupied := make(map[Pos]*Patroller)
+	for i, g := range guards {
+		policy := turnRight
+		if len(policies) > 0 {
+			policy = policies[min(i, len(policies)-1)]
+		}
+		ps[i] = &Patroller{
+			Guard:   *g,
+			policy:  policy,
+			visited: map[Pos]bool{g.p: true},
+		}
+		occupied[g.p] = ps[i]
+	}
+
+	seen := make(map[string]bool)
+	for {
+		key := patrolKey(ps)
+		if seen[key] {
+			break
+		}
+		seen[key] = true
+
+		active := 0
+		for _, p := range ps {
+			if p.exited {
+				continue
+			}
+			active++
+
+			next := p.p.Step(p.Direction())
+			c, ok := grid.At(next)
+			switch {
+			case !ok:
+				p.exited = true
+				delete(occupied, p.p)
+			case c == obstruction || c == newObstruction || occupied[next] != nil:
+				p.Turn()
+			default:
+				delete(occupied, p.p)
+				p.p = next
+				occupied[next] = p
+				p.visited[next] = true
+			}
+		}
+		if active == 0 {
+			break
+		}
+	}
+
+	ret := make([]PatrolResult, len(ps))
+	for i, p := range ps {
+		ret[i] = PatrolResult{
+			Start:   *guards[i],
+			Policy:  p.policy,
+			Visited: len(p.visited),
+			Exited:  p.exited,
+		}
+	}
+	return ret
+}
+
+// patrolKey identifies the state of every guard, including which way the
+// alternating ones will turn next.
+func patrolKey(ps []*Patroller) string {
+	var b strings.Builder
+	for _, p := range ps {
+		if p.exited {
+			b.WriteString("x;")
+			continue
+		}
+		fmt.Fprintf(&b, "%d,%d,%c,%d;", p.p.x, p.p.y, p.c, p.turns%2)
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package main
+
+// Jumps is an acceleration structure for moving the guard. For every cell and
+// direction it holds the cell where a guard walking that way stops in front of
+// an obstruction, so the guard can jump straight from turn to turn.
+type Jumps struct {
+	width, height int
+	stops         []int // state index -> cell index, or -1 if the guard leaves
+}
+
+func NewJumps(g *Grid) *Jumps {
+	j := &Jumps{
+		width:  g.width,
+		height: g.height,
+		stops:  make([]int, g.width*g.height*4),
+	}
+	for y := 0; y < g.height; y++ {
+		j.updateRow(g, y)
+	}
+	for x := 0; x < g.width; x++ {
+		j.updateColumn(g, x)
+	}
+	return j
+}
+
+func (j *Jumps) Clone() *Jumps {
+	return &Jumps{
+		width:  j.width,
+		height: j.height,
+		stops:  append([]int(nil), j.stops...),
+	}
+}
+
+func (j *Jumps) cell(p Pos) int { return p.y*j.width + p.x }
+
+func (j *Jumps) pos(cell int) Pos { return Pos{cell % j.width, cell / j.width} }
+
+// Update recomputes the stops affected by adding or removing an obstruction at
+// p, which are only those in the same row and column.
+func (j *Jumps) Update(g *Grid, p Pos) {
+	j.updateRow(g, p.y)
+	j.updateColumn(g, p.x)
+}
+
+func (j *Jumps) updateRow(g *Grid, y int) {
+	stop := -1
+	for x := 0; x < g.width; x++ {
+		p := Pos{x, y}
+		if c, _ := g.At(p); c == obstruction || c == newObstruction {
+			stop = j.cell(p.Right())
+			continue
+		}
+		j.stops[j.cell(p)*4+int(left)] = stop
+	}
+	stop = -1
+	for x := g.width - 1; x >= 0; x-- {
+		p := Pos{x, y}
+		if c, _ := g.At(p); c == obstruction || c == newObstruction {
+			stop = j.cell(p.Left())
+			continue
+		}
+		j.stops[j.cell(p)*4+int(right)] = stop
+	}
+}
+
+func (j *Jumps) updateColumn(g *Grid, x int) {
+	stop := -1
+	for y := 0; y < g.height; y++ {
+		p := Pos{x, y}
+		if c, _ := g.At(p); c == obstruction || c == newObstruction {
+			stop = j.cell(p.Down())
+			continue
+		}
+		j.stops[j.cell(p)*4+int(up)] = stop
+	}
+	stop = -1
+	for y := g.height - 1; y >= 0; y-- {
+		p := Pos{x, y}
+		if c, _ := g.At(p); c == obstruction || c == newObstruction {
+			stop = j.cell(p.Up())
+			continue
+		}
+		j.stops[j.cell(p)*4+int(down)] = stop
+	}
+}
+
+// Loops jumps the guard from turn to turn and reports whether it gets stuck.
+// Only the states where the guard turns are recorded in seen, which is
+// cleared first.
+func (j *Jumps) Loops(guard *Guard, seen states) bool {
+	seen.Clear()
+	cell, d := j.cell(guard.p), guard.Direction()
+	for {
+		cell = j.stops[cell*4+int(d)]
+		if cell < 0 {
+			return false
+		}
+		i := cell*4 + int(d)
+		if seen.Has(i) {
+			return true
+		}
+		seen.Set(i)
+		d = (d + 1) % 4
+	}
+}
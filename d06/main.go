@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"runtime"
+	"strings"
 	"time"
 
 	tm "github.com/buger/goterm"
@@ -14,16 +16,18 @@ import (
 var (
 	debug    = flag.Bool("debug", false, "debug mode")
 	pristine = flag.Bool("pristine", false, "don't modify the grid")
+	workers  = flag.Int("workers", runtime.NumCPU(), "parallel obstruction searches")
+	compare  = flag.Bool("compare", false, "time searching the path against every cell")
+	jump     = flag.Bool("jump", false, "move the guard with a precomputed jump table")
+	browser  = flag.Bool("browse", false, "step through each looping obstruction and its loop")
+	toJSON   = flag.Bool("json", false, "write looping obstructions and their loops as JSON")
+	turns    = flag.String("turns", "", "turn policy per guard (right, left, reverse or alternating), comma separated")
 )
 
 type Cell rune
 
 const (
 	unvisited      Cell = '.'
-	visitedUp      Cell = '1'
-	visitedDown    Cell = 'l'
-	visitedLeft    Cell = '-'
-	visitedRight   Cell = '_'
 	obstruction    Cell = '#'
 	newObstruction Cell = 'O'
 	guardUp        Cell = '^'
@@ -51,15 +55,6 @@ func (c Cell) IsGuard() bool {
 	}
 }
 
-func (c Cell) IsVisited() bool {
-	switch c {
-	case visitedUp, visitedDown, visitedLeft, visitedRight:
-		return true
-	default:
-		return false
-	}
-}
-
 type Pos struct {
 	x, y int
 }
@@ -69,14 +64,37 @@ func (p Pos) Right() Pos { return Pos{p.x + 1, p.y} }
 func (p Pos) Down() Pos  { return Pos{p.x, p.y + 1} }
 func (p Pos) Left() Pos  { return Pos{p.x - 1, p.y} }
 
+func (p Pos) Step(d Direction) Pos {
+	switch d {
+	case up:
+		return p.Up()
+	case right:
+		return p.Right()
+	case down:
+		return p.Down()
+	default:
+		return p.Left()
+	}
+}
+
+// Direction is the way a guard is facing, in the order they turn.
+type Direction int
+
+const (
+	up Direction = iota
+	right
+	down
+	left
+)
+
+func (d Direction) Vertical() bool { return d == up || d == down }
+
 type Guard struct {
-	p       Pos
-	c       Cell
-	turning bool
+	p Pos
+	c Cell
 }
 
 func (g *Guard) Turn() {
-	g.turning = true
 	switch g.c {
 	case guardUp:
 		g.c = guardRight
@@ -91,34 +109,44 @@ func (g *Guard) Turn() {
 	}
 }
 
-func (g *Guard) Direction() Cell {
+func (g *Guard) Direction() Direction {
 	switch g.c {
 	case guardUp:
-		return visitedUp
+		return up
+	case guardRight:
+		return right
 	case guardDown:
-		return visitedDown
+		return down
 	case guardLeft:
-		return visitedLeft
-	case guardRight:
-		return visitedRight
+		return left
 	default:
 		log.Fatalf("invalid guard %q", g.c)
-		return unvisited
+		return up
 	}
 }
 
-func (g *Guard) Visited(grid *Grid) {
-	c := g.Direction()
-	if g.turning {
-		g.turning = false
-	}
-	grid.Set(g.p, c)
-}
+// states is a bitset of every (position, direction) a guard has been in.
+type states []uint64
+
+func (s states) Has(i int) bool { return s[i/64]&(1<<(i%64)) != 0 }
+func (s states) Set(i int)      { s[i/64] |= 1 << (i % 64) }
+func (s states) Clear()         { clear(s) }
 
 type Grid struct {
 	cells         [][]Cell
 	width, height int
 	guard         *Guard
+	seen          states
+}
+
+// state returns the index into seen for a guard at p facing d.
+func (g *Grid) state(p Pos, d Direction) int {
+	return (p.y*g.width+p.x)*4 + int(d)
+}
+
+// Seen reports whether a guard has been at p facing d.
+func (g *Grid) Seen(p Pos, d Direction) bool {
+	return g.seen != nil && g.seen.Has(g.state(p, d))
 }
 
 func (g *Grid) ResetGuard(guard *Guard) {
@@ -131,12 +159,13 @@ func (g *Grid) ResetGuard(guard *Guard) {
 func (g *Grid) Reset() {
 	for y, row := range g.cells {
 		for x, cell := range row {
-			if cell.IsVisited() || cell == newObstruction {
+			if cell == newObstruction {
 				pos := Pos{x, y}
 				g.Set(pos, unvisited)
 			}
 		}
 	}
+	g.seen.Clear()
 }
 
 func (g *Grid) Validate() {
@@ -154,15 +183,9 @@ func (g *Grid) Validate() {
 func (g *Grid) Render() {
 	tm.MoveCursor(1, 1)
 
-	for _, row := range g.cells {
-		for _, cell := range row {
-			switch cell {
-			case visitedUp, visitedDown:
-				cell = '|'
-			case visitedLeft, visitedRight:
-				cell = '-'
-			}
-			tm.Printf("%c ", cell)
+	for y, row := range g.cells {
+		for x, cell := range row {
+			tm.Printf("%c ", g.display(Pos{x, y}, cell))
 		}
 		tm.Println()
 	}
@@ -181,19 +204,40 @@ func (g *Grid) RenderPlain() {
 			if g.guard != nil && g.guard.p == pos {
 				fmt.Printf("%c ", g.guard.c)
 			} else {
-				switch cell {
-				case visitedUp, visitedDown:
-					cell = '|'
-				case visitedLeft, visitedRight:
-					cell = '-'
-				}
-				fmt.Printf("%c ", cell)
+				fmt.Printf("%c ", g.display(pos, cell))
 			}
 		}
 		fmt.Println()
 	}
 }
 
+// display returns the rune to draw for cell at p, showing the guard's path.
+func (g *Grid) display(p Pos, cell Cell) rune {
+	if cell != unvisited {
+		return rune(cell)
+	}
+	var vertical, horizontal bool
+	for d := up; d <= left; d++ {
+		if g.Seen(p, d) {
+			if d.Vertical() {
+				vertical = true
+			} else {
+				horizontal = true
+			}
+		}
+	}
+	switch {
+	case vertical && horizontal:
+		return '+'
+	case vertical:
+		return '|'
+	case horizontal:
+		return '-'
+	default:
+		return rune(cell)
+	}
+}
+
 func (g *Grid) At(p Pos) (_ Cell, ok bool) {
 	if p.y > g.height-1 || p.y < 0 || p.x > g.width-1 || p.x < 0 {
 		return Cell(' '), false
@@ -205,69 +249,71 @@ func (g *Grid) Set(p Pos, c Cell) {
 	g.cells[p.y][p.x] = c
 }
 
-func (g *Grid) Iterate() {
-	if g.guard == nil {
-		return
-	}
-
-	var next Pos
-	switch g.guard.c {
-	case guardUp:
-		next = g.guard.p.Up()
-	case guardRight:
-		next = g.guard.p.Right()
-	case guardDown:
-		next = g.guard.p.Down()
-	case guardLeft:
-		next = g.guard.p.Left()
-	default:
-		log.Fatalf("unexpected guard %c at %v", g.guard.c, g.guard.p)
-	}
-
+// Next returns the guard's state after it takes one step or turns, or false if
+// it walks off the grid. Neither the grid nor guard are changed.
+func (g *Grid) Next(guard Guard) (Guard, bool) {
+	next := guard.p.Step(guard.Direction())
 	target, ok := g.At(next)
 	if !ok {
 		// Bye!
-		g.guard.Visited(g)
-		g.guard = nil
-		return
+		return guard, false
 	}
 	switch target {
-	case unvisited, visitedUp, visitedDown, visitedLeft, visitedRight:
-		g.guard.Visited(g)
-		g.guard.p = next
+	case unvisited:
+		guard.p = next
 	case obstruction, newObstruction:
-		g.guard.Turn()
+		guard.Turn()
 	default:
 		log.Fatalf("unexpected target cell %c", target)
 	}
+	return guard, true
+}
+
+// mark records that a guard has been in the given state.
+func (g *Grid) mark(guard Guard) {
+	if g.seen == nil {
+		g.seen = make(states, (g.width*g.height*4+63)/64)
+	}
+	g.seen.Set(g.state(guard.p, guard.Direction()))
+}
+
+func (g *Grid) Iterate() {
+	if g.guard == nil {
+		return
+	}
+
+	g.mark(*g.guard)
+	next, ok := g.Next(*g.guard)
+	if !ok {
+		g.guard = nil
+		return
+	}
+	*g.guard = next
 }
 
 func (g *Grid) Visited() []Pos {
 	var ret []Pos
-	for x, row := range g.cells {
-		for y, cell := range row {
-			if cell.IsVisited() {
-				ret = append(ret, Pos{x, y})
+	for y, row := range g.cells {
+		for x := range row {
+			for d := up; d <= left; d++ {
+				if g.Seen(Pos{x, y}, d) {
+					ret = append(ret, Pos{x, y})
+					break
+				}
 			}
 		}
 	}
 	return ret
 }
 
+// Stuck reports whether the guard is in a loop. The guard's movement depends
+// only on its position and direction, so being in the same state twice means
+// it will repeat the same path forever.
 func (g *Grid) Stuck() bool {
 	if g.guard == nil {
 		return false
 	}
-	current, ok := g.At(g.guard.p)
-	if !ok {
-		return false
-	}
-	switch current {
-	case g.guard.Direction():
-		return true
-	default:
-		return false
-	}
+	return g.Seen(g.guard.p, g.guard.Direction())
 }
 
 func get[T any](v T, err error) T {
@@ -289,7 +335,7 @@ func main() {
 	defer f.Close()
 
 	var cells [][]Cell
-	guard := &Guard{}
+	var guards []*Guard
 
 	s := bufio.NewScanner(f)
 	y := 0
@@ -299,9 +345,7 @@ func main() {
 		for x, l := range line {
 			c := get(NewCell(l))
 			if c.IsGuard() {
-				guard.c = c
-				guard.p.x = x
-				guard.p.y = y
+				guards = append(guards, &Guard{p: Pos{x, y}, c: c})
 				c = unvisited
 			}
 			row = append(row, c)
@@ -314,6 +358,9 @@ func main() {
 	if len(cells) == 0 {
 		log.Fatal("empty grid")
 	}
+	if len(guards) == 0 {
+		log.Fatal("no guard")
+	}
 
 	grid := &Grid{
 		cells:  cells,
@@ -322,32 +369,52 @@ func main() {
 	}
 	grid.Validate()
 
-	run(grid, guard)
-	visited := grid.Visited()
-	fmt.Printf("\n\nvisited: %d\n", len(visited))
+	if len(guards) > 1 || *turns != "" {
+		var policies []TurnPolicy
+		if *turns != "" {
+			for _, t := range strings.Split(*turns, ",") {
+				policies = append(policies, get(NewTurnPolicy(t)))
+			}
+		}
+		for _, r := range patrolAll(grid, guards, policies) {
+			fmt.Println(r)
+		}
+		return
+	}
+
+	guard := guards[0]
+	res := run(grid, guard)
+	if !*toJSON {
+		fmt.Printf("\n\nvisited: %d\n", len(res.Visited))
+	}
 
 	if !*pristine {
-		var obstructions []Pos
-
 		grid.Reset()
 
-		for y := 0; y < grid.height; y++ {
-			for x := 0; x < grid.width; x++ {
-				pos := Pos{x, y}
-				if c, ok := grid.At(pos); !ok || c != unvisited {
-					continue
-				}
-				grid.Set(pos, newObstruction)
-				run(grid, guard)
-				if grid.Stuck() {
-					// fmt.Printf("\nfound looping obstruction at %v\n", pos)
-					// grid.RenderPlain()
-					// fmt.Printf("\n")
-					obstructions = append(obstructions, pos)
+		start := time.Now()
+		obstructions := searchPath(grid, guard, *workers, *jump)
+		elapsed := time.Since(start)
+
+		if *compare {
+			start := time.Now()
+			all := searchGrid(grid, guard)
+			baseline := time.Since(start)
+			fmt.Printf("\nwhole grid: %d obstructions in %s\n", len(all), baseline)
+			fmt.Printf("path only: %d obstructions in %s (%d workers)\n", len(obstructions), elapsed, *workers)
+			fmt.Printf("speedup: %.1fx\n", baseline.Seconds()/elapsed.Seconds())
+		}
+
+		if *browser || *toJSON {
+			loops := loopsFor(grid, guard, obstructions)
+			if *toJSON {
+				if err := writeLoopsJSON(os.Stdout, loops); err != nil {
+					log.Fatal(err)
 				}
-				grid.Reset()
+				return
 			}
+			browse(grid, loops)
 		}
+
 		if *debug {
 			grid.Reset()
 			grid.ResetGuard(guard)
@@ -360,17 +427,24 @@ func main() {
 	}
 }
 
-func run(grid *Grid, guard *Guard) {
-	grid.ResetGuard(guard)
-	tm.Clear()
-	for grid.guard != nil && !grid.Stuck() {
-		if *debug {
-			grid.Render()
-			time.Sleep(time.Millisecond * 1)
-		}
-		grid.Iterate()
+// run simulates the guard, animating its patrol in debug mode.
+func run(grid *Grid, guard *Guard) Result {
+	res := Simulate(grid, guard)
+	if !*debug {
+		return res
 	}
-	if *debug {
+
+	tm.Clear()
+	grid.seen.Clear()
+	for i := range res.Path {
+		grid.guard = &res.Path[i]
 		grid.Render()
+		time.Sleep(time.Millisecond * 1)
+		grid.mark(res.Path[i])
+	}
+	if res.Exited {
+		grid.guard = nil
 	}
+	grid.Render()
+	return res
 }
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// newGrid returns the grid and guard drawn in rows.
+func newGrid(t *testing.T, rows ...string) (*Grid, *Guard) {
+	t.Helper()
+	guard := &Guard{}
+	var cells [][]Cell
+	for y, line := range rows {
+		var row []Cell
+		for x, r := range line {
+			c, err := NewCell(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.IsGuard() {
+				guard.p, guard.c = Pos{x, y}, c
+				c = unvisited
+			}
+			row = append(row, c)
+		}
+		cells = append(cells, row)
+	}
+	return &Grid{cells: cells, width: len(cells[0]), height: len(cells)}, guard
+}
+
+func TestSimulate(t *testing.T) {
+	testcases := []struct {
+		name    string
+		rows    []string
+		loop    bool
+		visited int
+		cycle   int // states in the loop
+	}{
+		{"crosses its path", []string{
+			"..#...",
+			".....#",
+			"......",
+			"..^...",
+			"....#.",
+		}, false, 10, 0},
+		{"loop", []string{
+			".#...",
+			"....#",
+			".^...",
+			"#....",
+			"...#.",
+		}, true, 8, 12},
+		{"example", []string{
+			"....#.....",
+			".........#",
+			"..........",
+			"..#.......",
+			".......#..",
+			"..........",
+			".#..^.....",
+			"........#.",
+			"#.........",
+			"......#...",
+		}, false, 41, 0},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			grid, guard := newGrid(t, tc.rows...)
+			res := Simulate(grid, guard)
+
+			if got, want := res.Exited, !tc.loop; got != want {
+				t.Errorf("wrong exited. got = %v, want = %v", got, want)
+			}
+			if got, want := len(res.Visited), tc.visited; got != want {
+				t.Errorf("wrong result. got = %d, want = %d", got, want)
+			}
+			if got, want := len(res.Cycle()), tc.cycle; got != want {
+				t.Errorf("wrong cycle. got = %d, want = %d", got, want)
+			}
+			if tc.loop {
+				// The loop starts with the state after the last one
+				if next, _ := grid.Next(res.Path[len(res.Path)-1]); next != res.Path[res.Loop] {
+					t.Errorf("wrong loop. got = %v, want = %v", res.Path[res.Loop], next)
+				}
+			}
+		})
+	}
+}
+
+// randomGrid returns an 8x8 grid with obstructions in about one cell in six,
+// and the guard facing up somewhere else.
+func randomGrid(seed int64) []string {
+	r := rand.New(rand.NewSource(seed))
+	rows := make([][]byte, 8)
+	for y := range rows {
+		rows[y] = make([]byte, 8)
+		for x := range rows[y] {
+			rows[y][x] = '.'
+			if r.Intn(6) == 0 {
+				rows[y][x] = '#'
+			}
+		}
+	}
+	rows[r.Intn(8)][r.Intn(8)] = '^'
+
+	var ret []string
+	for _, row := range rows {
+		ret = append(ret, string(row))
+	}
+	return ret
+}
+
+func TestSearch(t *testing.T) {
+	testcases := []struct {
+		name string
+		rows []string
+		want int
+	}{
+		{"example", []string{
+			"....#.....",
+			".........#",
+			"..........",
+			"..#.......",
+			".......#..",
+			"..........",
+			".#..^.....",
+			"........#.",
+			"#.........",
+			"......#...",
+		}, 6},
+		{"already loops", []string{
+			".#...",
+			"....#",
+			".^...",
+			"#....",
+			"...#.",
+		}, 13},
+		{"crosses its path", []string{
+			"..#...",
+			".....#",
+			"......",
+			"..^...",
+			"....#.",
+		}, 1},
+	}
+
+	for seed := range int64(20) {
+		testcases = append(testcases, struct {
+			name string
+			rows []string
+			want int
+		}{fmt.Sprintf("random %d", seed), randomGrid(seed), -1})
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			grid, guard := newGrid(t, tc.rows...)
+			want := searchGrid(grid, guard)
+			if got := len(want); tc.want >= 0 && got != tc.want {
+				t.Errorf("wrong result. got = %d, want = %d", got, tc.want)
+			}
+			for _, workers := range []int{1, 4} {
+				for _, jump := range []bool{false, true} {
+					got := searchPath(grid, guard, workers, jump)
+					if !slices.Equal(got, want) {
+						t.Errorf("%d workers, jump %v: wrong result. got = %v, want = %v", workers, jump, got, want)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"slices"
+	"sync"
+)
+
+// Clone returns a copy of the grid which can be simulated independently.
+func (g *Grid) Clone() *Grid {
+	cells := make([][]Cell, len(g.cells))
+	for y, row := range g.cells {
+		cells[y] = append([]Cell(nil), row...)
+	}
+	return &Grid{
+		cells:  cells,
+		width:  g.width,
+		height: g.height,
+	}
+}
+
+// loops runs the guard without rendering and reports whether it gets stuck.
+func loops(grid *Grid, guard *Guard) bool {
+	grid.ResetGuard(guard)
+	for grid.guard != nil && !grid.Stuck() {
+		grid.Iterate()
+	}
+	return grid.Stuck()
+}
+
+// searchGrid tries an obstruction in every empty cell of the grid apart from
+// the guard's start, running the guard from the start each time.
+func searchGrid(grid *Grid, guard *Guard) []Pos {
+	var obstructions []Pos
+	for y := 0; y < grid.height; y++ {
+		for x := 0; x < grid.width; x++ {
+			pos := Pos{x, y}
+			if c, ok := grid.At(pos); !ok || c != unvisited || pos == guard.p {
+				continue
+			}
+			grid.Set(pos, newObstruction)
+			if loops(grid, guard) {
+				obstructions = append(obstructions, pos)
+			}
+			grid.Reset()
+		}
+	}
+	return obstructions
+}
+
+type trial struct {
+	obstruction Pos
+	from        Guard // the guard's state just before reaching obstruction
+}
+
+// searchPath tries an obstruction only in cells on the guard's original path,
+// since an obstruction anywhere else would never be reached. (If the guard is
+// stuck even without one, every other empty cell counts as a loop too.) Each
+// trial starts from the guard's state just before it first walks into the
+// obstruction, as its path up to there is unchanged. Trials are shared
+// between workers, each with its own copy of the grid. If jump is set, the
+// guard moves using a jump table updated for each obstruction.
+func searchPath(grid *Grid, guard *Guard, workers int, jump bool) []Pos {
+	var trials []trial
+	tried := map[Pos]bool{guard.p: true}
+	res := Simulate(grid, guard)
+	for _, g := range res.Path {
+		next := g.p.Step(g.Direction())
+		if c, ok := grid.At(next); !ok || c != unvisited || tried[next] {
+			continue
+		}
+		tried[next] = true
+		trials = append(trials, trial{next, g})
+	}
+
+	workers = max(1, min(workers, len(trials)))
+	found := make([]bool, len(trials))
+	jobs := make(chan int)
+	var jumps *Jumps
+	if jump {
+		jumps = NewJumps(grid)
+	}
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			grid := grid.Clone()
+			if jumps == nil {
+				for i := range jobs {
+					t := trials[i]
+					grid.Set(t.obstruction, newObstruction)
+					found[i] = loops(grid, &t.from)
+					grid.Reset()
+				}
+				return
+			}
+
+			jumps := jumps.Clone()
+			seen := make(states, (grid.width*grid.height*4+63)/64)
+			for i := range jobs {
+				t := trials[i]
+				grid.Set(t.obstruction, newObstruction)
+				jumps.Update(grid, t.obstruction)
+				found[i] = jumps.Loops(&t.from, seen)
+				grid.Set(t.obstruction, unvisited)
+				jumps.Update(grid, t.obstruction)
+			}
+		}()
+	}
+	for i := range trials {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var obstructions []Pos
+	for i, t := range trials {
+		if found[i] {
+			obstructions = append(obstructions, t.obstruction)
+		}
+	}
+	if !res.Exited {
+		for y := 0; y < grid.height; y++ {
+			for x := 0; x < grid.width; x++ {
+				pos := Pos{x, y}
+				if c, _ := grid.At(pos); c == unvisited && !tried[pos] {
+					obstructions = append(obstructions, pos)
+				}
+			}
+		}
+	}
+
+	// Report in the same reading order as searching the whole grid
+	slices.SortFunc(obstructions, func(a, b Pos) int {
+		if a.y != b.y {
+			return a.y - b.y
+		}
+		return a.x - b.x
+	})
+	return obstructions
+}
@@ -0,0 +1,47 @@
+package main
+
+import "slices"
+
+// Result is the outcome of simulating a guard's patrol.
+type Result struct {
+	Path    []Guard // every state the guard was in, in order
+	Visited map[Pos]bool
+	Exited  bool // otherwise the guard is stuck in a loop
+	Steps   int  // moves and turns taken
+	Loop    int  // index in Path where the loop starts, if not Exited
+}
+
+// Cycle returns the states the guard repeats forever, or nil if it exited.
+func (r Result) Cycle() []Guard {
+	if r.Exited {
+		return nil
+	}
+	return r.Path[r.Loop:]
+}
+
+// Simulate runs the guard's patrol until it leaves the grid or gets stuck in a
+// loop. It doesn't change the grid or guard, or draw anything.
+func Simulate(grid *Grid, guard *Guard) Result {
+	res := Result{Visited: make(map[Pos]bool)}
+	seen := make(states, (grid.width*grid.height*4+63)/64)
+
+	g := *guard
+	for {
+		i := grid.state(g.p, g.Direction())
+		if seen.Has(i) {
+			res.Loop = slices.Index(res.Path, g)
+			return res
+		}
+		seen.Set(i)
+		res.Path = append(res.Path, g)
+		res.Visited[g.p] = true
+
+		next, ok := grid.Next(g)
+		res.Steps++
+		if !ok {
+			res.Exited = true
+			return res
+		}
+		g = next
+	}
+}
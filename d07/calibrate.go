@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Calibration is the result of solving one equation.
+type Calibration struct {
+	Equation *Equation
+	Solved   bool
+	GaveUp   bool // the equation took longer than the timeout
+}
+
+// calibrate solves each equation with ops on a pool of workers, setting the
+// operators of those it solves. Results are in the same order as equations.
+// If timeout isn't zero, equations taking longer are given up on. It's only
+// checked between evaluations of the equation, but no one evaluation takes
+// long, as operators like ^ limit how big their results can get. After each
+// equation, progress is called with how many are done; it must be safe to
+// call from any goroutine. If ctx is cancelled, calibrate stops and returns
+// its error.
+func calibrate(ctx context.Context, equations []*Equation, ops []Operator, backward bool, workers int, timeout time.Duration, progress func(done, total int)) ([]Calibration, error) {
+	results := make([]Calibration, len(equations))
+	jobs := make(chan int)
+
+	var mu sync.Mutex
+	done := 0
+
+	var wg sync.WaitGroup
+	for range max(1, workers) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = solveOne(ctx, equations[i], ops, backward, timeout)
+
+				mu.Lock()
+				done++
+				if progress != nil {
+					progress(done, len(equations))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for i := range equations {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func solveOne(ctx context.Context, e *Equation, ops []Operator, backward bool, timeout time.Duration) Calibration {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var solution []Operator
+	err := e.search(ctx, ops, backward, func(s []Operator) bool {
+		solution = s
+		return false
+	})
+
+	c := Calibration{Equation: e}
+	switch {
+	case solution != nil:
+		e.operators = solution
+		c.Solved = true
+	case errors.Is(err, context.DeadlineExceeded):
+		c.GaveUp = true
+	}
+	return c
+}
+
+// progressBar returns a progress func drawing a bar on w.
+func progressBar(w io.Writer) func(done, total int) {
+	const width = 40
+	return func(done, total int) {
+		filled := width * done / max(total, 1)
+		fmt.Fprintf(w, "\r[%s%s] %d/%d", strings.Repeat("#", filled), strings.Repeat(".", width-filled), done, total)
+		if done == total {
+			fmt.Fprintln(w)
+		}
+	}
+}
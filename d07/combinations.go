@@ -8,8 +8,8 @@ func combinations[T ~[]E, E any](options T, length int) iter.Seq[T] {
 		state := make([]int, length)
 
 		// total = pow(len(options), length)
-		total := len(options)
-		for i := 0; i < length-1; i++ {
+		total := 1
+		for i := 0; i < length; i++ {
 			total *= len(options)
 		}
 
@@ -0,0 +1,80 @@
+package main
+
+import "math/big"
+
+// evaluate works out the equation with ops, left to right. If a step
+// overflows an int, the rest is worked out with math/big, and the result is
+// returned as a *big.Int unless it comes back down to fit in an int. It fails
+// if an operator is unknown or undefined for its operands.
+//
+// With -precedence, operators bind by precedence instead.
+//
+// If prune is set, it also fails as soon as the result has passed the total
+// and no remaining step can bring it back down.
+func (e *Equation) evaluate(ops []Operator, prune bool) (int, *big.Int, bool) {
+	if *precedence {
+		return e.evaluatePrecedence(ops)
+	}
+
+	// grows[i] is set if none of the steps from i on can make the result smaller
+	var grows []bool
+	if prune {
+		grows = make([]bool, len(ops)+1)
+		grows[len(ops)] = true
+		for i := len(ops) - 1; i >= 0; i-- {
+			op, ok := operators[ops[i]]
+			grows[i] = grows[i+1] && ok && op.Grows && e.operands[i+1] >= 1
+		}
+	}
+
+	total := e.operands[0]
+	for i, o := range ops {
+		op, ok := operators[o]
+		if !ok {
+			return 0, nil, false
+		}
+		y := e.operands[i+1]
+
+		if op.Overflows != nil && op.Overflows(total, y) {
+			if prune && total >= 0 && grows[i] {
+				// The real result is bigger than any int, so bigger than
+				// the total, and can only get bigger
+				return 0, nil, false
+			}
+			return e.evaluateBig(ops, i, big.NewInt(int64(total)))
+		}
+
+		if total, ok = op.Apply(total, y); !ok {
+			return 0, nil, false
+		}
+		if prune && total > e.total && total >= 0 && grows[i+1] {
+			return 0, nil, false
+		}
+	}
+	return total, nil, true
+}
+
+// evaluateBig carries on evaluating from step i with math/big.
+func (e *Equation) evaluateBig(ops []Operator, i int, total *big.Int) (int, *big.Int, bool) {
+	for ; i < len(ops); i++ {
+		op := operators[ops[i]]
+		if op.ApplyBig == nil {
+			return 0, nil, false
+		}
+
+		var ok bool
+		if total, ok = op.ApplyBig(total, big.NewInt(int64(e.operands[i+1]))); !ok {
+			return 0, nil, false
+		}
+	}
+	if total.IsInt64() {
+		return int(total.Int64()), nil, true
+	}
+	return 0, total, true
+}
+
+// reaches reports whether the equation is true using ops.
+func (e *Equation) reaches(ops []Operator) bool {
+	n, b, ok := e.evaluate(ops, true)
+	return ok && b == nil && n == e.total
+}
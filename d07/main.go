@@ -2,16 +2,33 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
+
+	"github.com/mattn/go-isatty"
 )
 
-var debug = flag.Bool("debug", false, "show equations")
+var (
+	debug            = flag.Bool("debug", false, "show equations")
+	backward         = flag.Bool("backward", false, "solve right to left from the total, pruning impossible branches")
+	compare          = flag.Bool("compare", false, "count operators evaluated by both solvers")
+	all              = flag.Bool("all", false, "list every solution for each equation")
+	count            = flag.Bool("count", false, "count the solutions for each equation")
+	precedence       = flag.Bool("precedence", false, "* binds tighter than +, rather than working left to right")
+	concatPrecedence = flag.String("concat", "tight", "with -precedence, how tightly | binds: tight, times or plus")
+	workers          = flag.Int("workers", runtime.NumCPU(), "equations to solve in parallel")
+	timeout          = flag.Duration("timeout", 0, "give up on any equation taking longer than this, checked between evaluations")
+	showProgress     = flag.Bool("progress", true, "show a progress bar on stderr when it's a terminal")
+	ops              = flag.String("ops", "+*|", "operators for the second pass: any of + * | - / ^ % and x for xor")
+)
 
 func get[T any](v T, err error) T {
 	if err != nil {
@@ -63,38 +80,29 @@ func (e *Equation) String() string {
 	return b.String()
 }
 
+// Evaluate works out the equation left to right. It fails if any operator is
+// unknown or undefined for its operands, or the result doesn't fit in an int.
 func (e *Equation) Evaluate() (int, bool) {
-	total := e.operands[0]
-	for i, op := range e.operators {
-		switch op {
-		case plus:
-			total = total + e.operands[i+1]
-		case times:
-			total = total * e.operands[i+1]
-		case concat:
-			total = concatenate(total, e.operands[i+1])
-		default:
-			return 0, false
-		}
-	}
-	return total, true
+	n, b, ok := e.evaluate(e.operators, false)
+	return n, ok && b == nil
 }
 
 func concatenate(x, y int) int {
-	z := 10
-	for y >= z {
-		z *= 10
-	}
-	return x*z + y
+	return x*magnitude(y) + y
 }
 
-func (e *Equation) Solve(operators []Operator) {
+// Solve tries every combination of operators until one makes the equation
+// true. It returns how many operators were evaluated.
+func (e *Equation) Solve(operators []Operator) int {
+	nodes := 0
 	for combination := range combinations(operators, len(e.operators)) {
 		e.operators = combination
-		if total, ok := e.Evaluate(); ok && total == e.total {
-			return
+		nodes += len(combination)
+		if e.reaches(combination) {
+			return nodes
 		}
 	}
+	return nodes
 }
 
 func (e *Equation) Valid() bool {
@@ -127,9 +135,64 @@ func main() {
 		equations = append(equations, NewEquation(total, operands))
 	}
 
+	second := get(ParseOperators(*ops))
+	if err := SetConcatPrecedence(*concatPrecedence); err != nil {
+		log.Fatal(err)
+	}
+	if *precedence && (*compare || *backward) {
+		log.Fatal("can't solve backwards with -precedence")
+	}
+
+	if *all || *count {
+		for _, equation := range equations {
+			if *all {
+				for solution := range equation.Solutions(second) {
+					fmt.Printf("%s\n", equation.With(solution))
+				}
+			}
+			if *count {
+				fmt.Printf("%s: %d solutions\n", equation, equation.Count(second))
+			}
+		}
+		return
+	}
+
+	if (*compare || *backward) && !Invertible(second) {
+		log.Fatalf("operators %q can't all be solved backwards", string(second))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var progress func(done, total int)
+	if *showProgress && isatty.IsTerminal(os.Stderr.Fd()) {
+		progress = progressBar(os.Stderr)
+	}
+
+	var bruteNodes, backwardNodes int
+	gaveUp := make(map[*Equation]bool)
+	solve := func(equations []*Equation, operators []Operator) {
+		if *compare {
+			for _, e := range equations {
+				bruteNodes += e.Solve(operators)
+				backwardNodes += e.SolveBackward(operators)
+			}
+			return
+		}
+
+		results, err := calibrate(ctx, equations, operators, *backward, *workers, *timeout, progress)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, r := range results {
+			gaveUp[r.Equation] = r.GaveUp
+		}
+	}
+
 	total := 0
+	solve(equations, []Operator{'+', '*'})
+	var unsolved []*Equation
 	for _, equation := range equations {
-		equation.Solve([]Operator{'+', '*'})
 		if equation.Valid() {
 			if *debug {
 				fmt.Printf("%s: solved\n", equation)
@@ -139,21 +202,30 @@ func main() {
 			if *debug {
 				fmt.Printf("%s\n", equation)
 			}
+			unsolved = append(unsolved, equation)
 		}
 	}
 	fmt.Printf("two-operator total: %d\n\n", total)
 
-	for _, equation := range equations {
-		if equation.Valid() {
-			continue
-		}
-		equation.Solve([]Operator{'+', '*', '|'})
+	solve(unsolved, second)
+	for _, equation := range unsolved {
 		if equation.Valid() {
 			if *debug {
 				fmt.Printf("%s: solved\n", equation)
 			}
 			total += equation.total
+		} else if gaveUp[equation] {
+			fmt.Printf("%s: gave up\n", equation)
 		}
 	}
-	fmt.Printf("three-operator total: %d\n", total)
+	if *ops == "+*|" {
+		fmt.Printf("three-operator total: %d\n", total)
+	} else {
+		fmt.Printf("%q operator total: %d\n", *ops, total)
+	}
+
+	if *compare {
+		fmt.Printf("\nbrute force: %d operators evaluated\n", bruteNodes)
+		fmt.Printf("backward: %d operators undone\n", backwardNodes)
+	}
 }
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestEquation_Evaluate(t *testing.T) {
+	testcases := []struct {
+		name      string
+		operands  []int
+		operators string
+		want      int
+		ok        bool
+	}{
+		{"simple", []int{81, 40, 27}, "*+", 3267, true},
+		{"max", []int{math.MaxInt - 1, 1}, "+", math.MaxInt, true},
+		{"add overflow", []int{math.MaxInt, 1}, "+", 0, false},
+		{"mul overflow", []int{1 << 62, 4}, "*", 0, false},
+		{"concat max", []int{922337203685477580, 7}, "|", math.MaxInt, true},
+		{"concat overflow", []int{922337203685477580, 8}, "|", 0, false},
+		{"back down", []int{math.MaxInt, 2, math.MaxInt}, "+-", 2, true},
+		{"divide back down", []int{1 << 62, 8, 16}, "*/", 1 << 61, true},
+		{"power back down", []int{3, 50, 1000}, "^%", 249, true},
+		{"divide by zero", []int{1, 0}, "/", 0, false},
+		{"power of one", []int{1, 4000000000}, "^", 1, true},
+		{"power of minus one", []int{-1, 4000000001}, "^", -1, true},
+		{"power max", []int{-2, 63}, "^", math.MinInt, true},
+		{"power overflow", []int{2, 63}, "^", 0, false},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEquation(0, tc.operands).With([]Operator(tc.operators))
+
+			got, ok := e.Evaluate()
+			if ok != tc.ok {
+				t.Fatalf("wrong ok. got = %v, want = %v", ok, tc.ok)
+			}
+			if got, want := got, tc.want; ok && got != want {
+				t.Errorf("wrong result. got = %d, want = %d", got, want)
+			}
+		})
+	}
+}
+
+func TestEquation_Count(t *testing.T) {
+	testcases := []struct {
+		name      string
+		total     int
+		operands  []int
+		operators string
+		want      int
+	}{
+		{"example", 3267, []int{81, 40, 27}, "+*", 2},
+		{"wrapped product", 0, []int{1 << 62, 4}, "+*", 0},
+		{"wrapped concat", -6917529027641081856, []int{1 << 60, 0}, "+*|", 0},
+		{"max concat", math.MaxInt, []int{922337203685477580, 7}, "+*|", 1},
+		{"max sum", math.MaxInt, []int{math.MaxInt - 1, 1, 1}, "+*", 2},
+		{"past max", 2, []int{math.MaxInt, 2, math.MaxInt}, "+-", 1},
+		{"back to zero", 0, []int{math.MaxInt, 2, 0}, "+*", 2},
+		{"back to zero brute force", 0, []int{math.MaxInt, 2, 0}, "+*/", 3},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEquation(tc.total, tc.operands)
+
+			if got, want := e.Count([]Operator(tc.operators)), tc.want; got != want {
+				t.Errorf("wrong result. got = %d, want = %d", got, want)
+			}
+		})
+	}
+}
+
+func TestConcatenate(t *testing.T) {
+	for _, y := range []int{0, 9, 10, 99, 100, 123456} {
+		x := 42
+		want := strconv.Itoa(x) + strconv.Itoa(y)
+		if got := strconv.Itoa(concatenate(x, y)); got != want {
+			t.Errorf("wrong result. got = %s, want = %s", got, want)
+		}
+	}
+}
+
+func TestEquation_evaluatePrecedence(t *testing.T) {
+	testcases := []struct {
+		name      string
+		operands  []int
+		operators string
+		want      int
+	}{
+		{"times first", []int{11, 6, 16, 20}, "+*+", 127},
+		{"left to right", []int{100, 10, 5}, "-+", 95},
+		{"divide", []int{100, 10, 5}, "/*", 50},
+		{"power right", []int{2, 3, 2}, "^^", 512},
+		{"concat tight", []int{6, 8, 6, 15}, "*|*", 7740},
+		{"past max", []int{math.MaxInt, 2, 1, math.MaxInt}, "*/-", math.MaxInt},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := NewEquation(0, tc.operands)
+
+			got, b, ok := e.evaluatePrecedence([]Operator(tc.operators))
+			if !ok || b != nil {
+				t.Fatalf("no result")
+			}
+			if got, want := got, tc.want; got != want {
+				t.Errorf("wrong result. got = %d, want = %d", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Op defines how an operator behaves, so solvers don't need to know about
+// each one.
+type Op struct {
+	Symbol Operator
+
+	// Apply returns x op y, or false if that's undefined (like dividing by
+	// zero).
+	Apply func(x, y int) (int, bool)
+
+	// Inverse returns the x for which x op y == target, or false if there
+	// isn't one that fits in an int. It's nil if there could be many, which
+	// rules out solving backwards.
+	Inverse func(target, y int) (int, bool)
+
+	// Overflows reports whether x op y is too big for an int. It's nil if
+	// the operator can't overflow.
+	Overflows func(x, y int) bool
+
+	// Absorbs returns x op y if it's the same whatever x is, like anything
+	// times zero, or false otherwise. It's nil if that never happens.
+	Absorbs func(y int) (int, bool)
+
+	// ApplyBig is Apply for results which don't fit in an int.
+	ApplyBig func(x, y *big.Int) (*big.Int, bool)
+
+	// Negative is set if the operator can give a negative result from
+	// non-negative operands.
+	Negative bool
+
+	// Grows is set if x op y >= x whenever x >= 0 and y >= 1, so once a
+	// result is past the total it can't come back down.
+	Grows bool
+
+	// Precedence is how tightly the operator binds with -precedence.
+	Precedence int
+	RightAssoc bool
+}
+
+const (
+	minus  Operator = '-'
+	divide Operator = '/'
+	power  Operator = '^'
+	modulo Operator = '%'
+	xor    Operator = 'x'
+)
+
+// maxExponent and maxPowerBits limit ^ with math/big, so no one step takes
+// long.
+const (
+	maxExponent  = 1 << 16
+	maxPowerBits = 1 << 20
+)
+
+var operators = map[Operator]*Op{
+	plus: {
+		Symbol:     plus,
+		Apply:      func(x, y int) (int, bool) { return x + y, true },
+		Inverse:    func(target, y int) (int, bool) { return target - y, true },
+		Overflows:  addOverflows,
+		ApplyBig:   func(x, y *big.Int) (*big.Int, bool) { return new(big.Int).Add(x, y), true },
+		Grows:      true,
+		Precedence: precedencePlus,
+	},
+	times: {
+		Symbol: times,
+		Apply:  func(x, y int) (int, bool) { return x * y, true },
+		Inverse: func(target, y int) (int, bool) {
+			if y == 0 || target%y != 0 {
+				return 0, false
+			}
+			return target / y, true
+		},
+		Overflows:  mulOverflows,
+		Absorbs:    func(y int) (int, bool) { return 0, y == 0 },
+		ApplyBig:   func(x, y *big.Int) (*big.Int, bool) { return new(big.Int).Mul(x, y), true },
+		Grows:      true,
+		Precedence: precedenceTimes,
+	},
+	concat: {
+		Symbol: concat,
+		Apply:  func(x, y int) (int, bool) { return concatenate(x, y), true },
+		Inverse: func(target, y int) (int, bool) {
+			z := magnitude(y)
+			if (target-y)%z != 0 {
+				return 0, false
+			}
+			return (target - y) / z, true
+		},
+		Overflows: func(x, y int) bool {
+			z := magnitude(y)
+			return mulOverflows(x, z) || addOverflows(x*z, y)
+		},
+		ApplyBig: func(x, y *big.Int) (*big.Int, bool) {
+			z := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(len(y.String()))), nil)
+			return z.Mul(x, z).Add(z, y), true
+		},
+		Grows:      true,
+		Precedence: precedenceTight,
+	},
+	minus: {
+		Symbol: minus,
+		Apply:  func(x, y int) (int, bool) { return x - y, true },
+		Inverse: func(target, y int) (int, bool) {
+			if addOverflows(target, y) {
+				return 0, false
+			}
+			return target + y, true
+		},
+		Overflows:  func(x, y int) bool { return addOverflows(x, -y) || y == math.MinInt },
+		ApplyBig:   func(x, y *big.Int) (*big.Int, bool) { return new(big.Int).Sub(x, y), true },
+		Negative:   true,
+		Precedence: precedencePlus,
+	},
+	divide: {
+		Symbol: divide,
+		Apply: func(x, y int) (int, bool) {
+			if y == 0 {
+				return 0, false
+			}
+			return x / y, true
+		},
+		Overflows: func(x, y int) bool { return x == math.MinInt && y == -1 },
+		ApplyBig: func(x, y *big.Int) (*big.Int, bool) {
+			if y.Sign() == 0 {
+				return nil, false
+			}
+			return new(big.Int).Quo(x, y), true
+		},
+		Precedence: precedenceTimes,
+	},
+	power: {
+		Symbol: power,
+		Apply: func(x, y int) (int, bool) {
+			if y < 0 {
+				return 0, false
+			}
+			return pow(x, y), true
+		},
+		Overflows: powOverflows,
+		ApplyBig: func(x, y *big.Int) (*big.Int, bool) {
+			// Keep results a sensible size, as exponents can be huge with
+			// -precedence
+			if y.Sign() < 0 || y.Cmp(big.NewInt(maxExponent)) > 0 {
+				return nil, false
+			}
+			if x.BitLen()*int(y.Int64()) > maxPowerBits {
+				return nil, false
+			}
+			return new(big.Int).Exp(x, y, nil), true
+		},
+		Grows:      true,
+		Precedence: precedencePower,
+		RightAssoc: true,
+	},
+	modulo: {
+		Symbol: modulo,
+		Apply: func(x, y int) (int, bool) {
+			if y == 0 {
+				return 0, false
+			}
+			return x % y, true
+		},
+		ApplyBig: func(x, y *big.Int) (*big.Int, bool) {
+			if y.Sign() == 0 {
+				return nil, false
+			}
+			return new(big.Int).Rem(x, y), true
+		},
+		Precedence: precedenceTimes,
+	},
+	xor: {
+		Symbol:     xor,
+		Apply:      func(x, y int) (int, bool) { return x ^ y, true },
+		Inverse:    func(target, y int) (int, bool) { return target ^ y, true },
+		ApplyBig:   func(x, y *big.Int) (*big.Int, bool) { return new(big.Int).Xor(x, y), true },
+		Precedence: precedenceXor,
+	},
+}
+
+// ParseOperators returns the operators for each symbol in s, like "+*|".
+func ParseOperators(s string) ([]Operator, error) {
+	var ret []Operator
+	for _, r := range s {
+		if _, ok := operators[Operator(r)]; !ok {
+			return nil, fmt.Errorf("unknown operator %q", r)
+		}
+		ret = append(ret, Operator(r))
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("no operators")
+	}
+	return ret, nil
+}
+
+func addOverflows(x, y int) bool {
+	return (y > 0 && x > math.MaxInt-y) || (y < 0 && x < math.MinInt-y)
+}
+
+func mulOverflows(x, y int) bool {
+	if x == 0 || y == 0 {
+		return false
+	}
+	r := x * y
+	return r/y != x || (x == -1 && y == math.MinInt) || (y == -1 && x == math.MinInt)
+}
+
+// pow returns x to the power y by squaring, so it takes O(log y) steps.
+func pow(x, y int) int {
+	r := 1
+	for ; y > 0; y >>= 1 {
+		if y&1 == 1 {
+			r *= x
+		}
+		x *= x
+	}
+	return r
+}
+
+// powOverflows reports whether x to the power y is too big for an int. Powers
+// of -1, 0 and 1 never are, and any other x is past 63 bits within 64
+// multiplications.
+func powOverflows(x, y int) bool {
+	if y <= 0 || (x >= -1 && x <= 1) {
+		return false
+	}
+	if y >= 64 {
+		return true
+	}
+	r := 1
+	for range y {
+		if mulOverflows(r, x) {
+			return true
+		}
+		r *= x
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Operators bind in order of precedence when evaluating with -precedence, and
+// equal ones left to right unless they're right associative.
+const (
+	precedenceXor = iota + 1
+	precedencePlus
+	precedenceTimes
+	precedencePower
+	precedenceTight
+)
+
+// SetConcatPrecedence sets how tightly | binds: "tight" binds tighter than
+// anything else, while "times" and "plus" bind like * and +.
+func SetConcatPrecedence(s string) error {
+	switch s {
+	case "tight":
+		operators[concat].Precedence = precedenceTight
+	case "times":
+		operators[concat].Precedence = precedenceTimes
+	case "plus":
+		operators[concat].Precedence = precedencePlus
+	default:
+		return fmt.Errorf("unknown precedence %q", s)
+	}
+	return nil
+}
+
+// num is an intermediate result, held as an int until it overflows.
+type num struct {
+	n int
+	b *big.Int // set if n has overflowed
+}
+
+func (x num) big() *big.Int {
+	if x.b != nil {
+		return x.b
+	}
+	return big.NewInt(int64(x.n))
+}
+
+func (op *Op) apply(x, y num) (num, bool) {
+	if x.b == nil && y.b == nil && (op.Overflows == nil || !op.Overflows(x.n, y.n)) {
+		n, ok := op.Apply(x.n, y.n)
+		return num{n: n}, ok
+	}
+
+	b, ok := op.ApplyBig(x.big(), y.big())
+	if !ok {
+		return num{}, false
+	}
+	if b.IsInt64() {
+		return num{n: int(b.Int64())}, true
+	}
+	return num{b: b}, true
+}
+
+// evaluatePrecedence works out the equation with ops, binding tighter
+// operators first, using the shunting yard algorithm. It fails like evaluate.
+func (e *Equation) evaluatePrecedence(ops []Operator) (int, *big.Int, bool) {
+	values := []num{{n: e.operands[0]}}
+	var pending []*Op
+
+	// reduce applies the most recent pending operator to the last two values
+	reduce := func() bool {
+		op := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		x, y := values[len(values)-2], values[len(values)-1]
+		values = values[:len(values)-2]
+
+		r, ok := op.apply(x, y)
+		values = append(values, r)
+		return ok
+	}
+
+	for i, o := range ops {
+		op, ok := operators[o]
+		if !ok {
+			return 0, nil, false
+		}
+		for len(pending) > 0 {
+			top := pending[len(pending)-1]
+			if top.Precedence < op.Precedence || (top.Precedence == op.Precedence && op.RightAssoc) {
+				break
+			}
+			if !reduce() {
+				return 0, nil, false
+			}
+		}
+		pending = append(pending, op)
+		values = append(values, num{n: e.operands[i+1]})
+	}
+	for len(pending) > 0 {
+		if !reduce() {
+			return 0, nil, false
+		}
+	}
+
+	return values[0].n, values[0].b, true
+}
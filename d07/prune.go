@@ -0,0 +1,76 @@
+package main
+
+// SolveBackward finds operators which make the equation true, like Solve, but
+// works right to left from the total. Each operator is undone against the
+// last operand, and branches where that's impossible are pruned: a product
+// must divide exactly, a concatenation must end in the operand's digits, and
+// no partial result can be negative. It returns how many operators were
+// undone, to compare against the brute force approach. Every operator must be
+// Invertible.
+func (e *Equation) SolveBackward(ops []Operator) int {
+	nodes := 0
+	var solve func(target, i int) bool
+	solve = func(target, i int) bool {
+		if i == 0 {
+			return target == e.operands[0]
+		}
+
+		operand := e.operands[i]
+		for _, o := range ops {
+			nodes++
+			e.operators[i-1] = o
+			op := operators[o]
+			if op.Absorbs != nil {
+				if r, ok := op.Absorbs(operand); ok {
+					// The result is r whatever came before, so the rest
+					// doesn't matter
+					if target == r && e.fill(ops[0], i-1) {
+						return true
+					}
+					continue
+				}
+			}
+
+			x, ok := op.Inverse(target, operand)
+			if ok && x >= 0 && solve(x, i-1) {
+				return true
+			}
+		}
+		e.operators[i-1] = unknown
+		return false
+	}
+
+	solve(e.total, len(e.operands)-1)
+	return nodes
+}
+
+// Invertible reports whether every operator can be undone, so equations
+// using them can be solved backwards. Operators which can go negative are
+// ruled out too, as they could take a partial result beyond an int and back,
+// which working backwards can't follow.
+func Invertible(ops []Operator) bool {
+	for _, o := range ops {
+		if operators[o].Inverse == nil || operators[o].Negative {
+			return false
+		}
+	}
+	return true
+}
+
+// fill sets the first n operators to op. It's used when an operator absorbs
+// everything before it.
+func (e *Equation) fill(op Operator, n int) bool {
+	for i := range n {
+		e.operators[i] = op
+	}
+	return true
+}
+
+// magnitude is the power of ten which concatenating y shifts by.
+func magnitude(y int) int {
+	z := 10
+	for y >= z {
+		z *= 10
+	}
+	return z
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"iter"
+	"slices"
+)
+
+// With returns a copy of the equation using the given operators.
+func (e *Equation) With(ops []Operator) *Equation {
+	return &Equation{
+		total:     e.total,
+		operands:  e.operands,
+		operators: slices.Clone(ops),
+	}
+}
+
+// Solutions yields every assignment of operators which makes the equation
+// true, without changing the equation. Each yielded slice is new, so callers
+// can keep it. Invertible operators are searched backwards, others (or any
+// with -precedence) by brute force.
+func (e *Equation) Solutions(ops []Operator) iter.Seq[[]Operator] {
+	backward := Invertible(ops) && !*precedence
+	return func(yield func([]Operator) bool) {
+		e.search(context.Background(), ops, backward, yield)
+	}
+}
+
+// checkEvery is how many nodes search explores backwards between checking its
+// context.
+const checkEvery = 1 << 12
+
+// search calls yield with each solution until it returns false, searching
+// backwards or by brute force. It stops early with ctx's error if ctx is done.
+func (e *Equation) search(ctx context.Context, ops []Operator, backward bool, yield func([]Operator) bool) error {
+	nodes := 0
+	cancelled := func() bool {
+		nodes++
+		return nodes%checkEvery == 0 && ctx.Err() != nil
+	}
+
+	if !backward {
+		for combination := range combinations(ops, len(e.operators)) {
+			// Each combination is a whole evaluation, so check every time
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if e.reaches(combination) {
+				if !yield(slices.Clone(combination)) {
+					return nil
+				}
+			}
+		}
+		return nil
+	}
+
+	current := make([]Operator, len(e.operators))
+
+	// solve yields every solution for the first i operands making target,
+	// and returns false once yield asks to stop or ctx is done
+	var solve func(target, i int) bool
+	solve = func(target, i int) bool {
+		if cancelled() {
+			return false
+		}
+		if i == 0 {
+			if target != e.operands[0] {
+				return true
+			}
+			return yield(slices.Clone(current))
+		}
+
+		operand := e.operands[i]
+		for _, o := range ops {
+			current[i-1] = o
+			op := operators[o]
+			if op.Absorbs != nil {
+				if r, ok := op.Absorbs(operand); ok {
+					// The result is r whatever came before, so every prefix
+					// works or none does
+					if target != r {
+						continue
+					}
+					for prefix := range combinations(ops, i-1) {
+						copy(current, prefix)
+						if !yield(slices.Clone(current)) {
+							return false
+						}
+					}
+					continue
+				}
+			}
+
+			x, ok := op.Inverse(target, operand)
+			if ok && x >= 0 && !solve(x, i-1) {
+				return false
+			}
+		}
+		return true
+	}
+
+	solve(e.total, len(e.operands)-1)
+	return ctx.Err()
+}
+
+// Count returns how many assignments of operators make the equation true.
+func (e *Equation) Count(ops []Operator) int {
+	n := 0
+	for range e.Solutions(ops) {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// frequencies returns every frequency with antennas, in order.
+func (m *Map) frequencies() []rune {
+	var ret []rune
+	for f := range m.antennas {
+		ret = append(ret, f)
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+// Antinodes returns the antinodes of frequency f in reading order.
+func (m *Map) Antinodes(f rune) []Pos {
+	var ret []Pos
+	for p := range m.sources[f] {
+		ret = append(ret, p)
+	}
+	slices.SortFunc(ret, func(a, b Pos) int {
+		if a.Less(b) {
+			return -1
+		}
+		if b.Less(a) {
+			return 1
+		}
+		return 0
+	})
+	return ret
+}
+
+// Overlap returns how many antinodes frequencies f and g have in common.
+func (m *Map) Overlap(f, g rune) int {
+	n := 0
+	for p := range m.sources[f] {
+		if _, ok := m.sources[g][p]; ok {
+			n++
+		}
+	}
+	return n
+}
+
+// Sources returns the pairs of antennas with frequency f causing an antinode
+// at p.
+func (m *Map) Sources(f rune, p Pos) []Pair {
+	return m.sources[f][p]
+}
+
+func (m *Map) PrintSummary() {
+	fs := m.frequencies()
+
+	fmt.Printf("\nantinodes by frequency:\n")
+	for _, f := range fs {
+		fmt.Printf("%c: %d\n", f, len(m.sources[f]))
+	}
+
+	shared := 0
+	for p := range m.antinodes {
+		n := 0
+		for _, f := range fs {
+			if _, ok := m.sources[f][p]; ok {
+				n++
+			}
+		}
+		if n > 1 {
+			shared++
+		}
+	}
+	fmt.Printf("\nshared by more than one frequency: %d\n", shared)
+	for i, f := range fs {
+		for _, g := range fs[i+1:] {
+			if n := m.Overlap(f, g); n > 0 {
+				fmt.Printf("%c & %c: %d\n", f, g, n)
+			}
+		}
+	}
+}
+
+func (m *Map) PrintFrequency(f rune) {
+	if _, ok := m.antennas[f]; !ok {
+		fmt.Printf("\nno antennas with frequency %c\n", f)
+		return
+	}
+
+	antinodes := m.Antinodes(f)
+	fmt.Printf("\nfrequency %c: %d antennas, %d antinodes\n", f, len(m.antennas[f]), len(antinodes))
+	for _, p := range antinodes {
+		fmt.Printf("%s:", p)
+		for _, pair := range m.Sources(f, p) {
+			fmt.Printf(" %s-%s", pair[0], pair[1])
+		}
+		fmt.Println()
+	}
+
+	for _, g := range m.frequencies() {
+		if g == f {
+			continue
+		}
+		if n := m.Overlap(f, g); n > 0 {
+			fmt.Printf("shared with %c: %d\n", g, n)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+
+	tm "github.com/buger/goterm"
+)
+
+// AntennaAt returns the frequency of the antenna at p, if there is one.
+func (m *Map) AntennaAt(p Pos) (rune, bool) {
+	for f, ps := range m.antennas {
+		if slices.Contains(ps, p) {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// PlaceAntenna puts an antenna with frequency f at p, replacing any antenna
+// already there. Only the antinodes it makes with other antennas of the same
+// frequency are added.
+func (m *Map) PlaceAntenna(f rune, p Pos) {
+	m.RemoveAntenna(p)
+	for _, q := range m.antennas[f] {
+		m.addPair(f, p, q)
+	}
+	m.antennas[f] = append(m.antennas[f], p)
+}
+
+// RemoveAntenna removes the antenna at p, if there is one, along with any
+// antinodes which no other pair of antennas causes.
+func (m *Map) RemoveAntenna(p Pos) {
+	f, ok := m.AntennaAt(p)
+	if !ok {
+		return
+	}
+	m.antennas[f] = slices.DeleteFunc(m.antennas[f], func(q Pos) bool { return q == p })
+	if len(m.antennas[f]) == 0 {
+		delete(m.antennas, f)
+	}
+
+	for antinode, pairs := range m.sources[f] {
+		pairs = slices.DeleteFunc(pairs, func(pair Pair) bool {
+			return pair[0] == p || pair[1] == p
+		})
+		if len(pairs) > 0 {
+			m.sources[f][antinode] = pairs
+			continue
+		}
+
+		delete(m.sources[f], antinode)
+		if !m.hasAntinode(antinode) {
+			delete(m.antinodes, antinode)
+		}
+	}
+}
+
+// hasAntinode reports whether any frequency has an antinode at p.
+func (m *Map) hasAntinode(p Pos) bool {
+	for _, antinodes := range m.sources {
+		if _, ok := antinodes[p]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Interact lets the user move a cursor around the map to place and remove
+// antennas, redrawing the antinodes after each line of input. The keys are
+// hjkl to move, f and a character to pick a frequency, + to place an antenna
+// of that frequency and - to remove one, and q to quit.
+func (m *Map) Interact(r io.Reader) {
+	cursor := Pos{0, 0}
+	frequency := 'A'
+
+	in := bufio.NewReader(r)
+	for {
+		m.print(&cursor)
+		tm.Printf("frequency %c at %s, antinodes: %d\n", frequency, cursor, len(m.antinodes))
+		tm.Printf("[hjkl] move, [f]requency, [+] place, [-] remove, [q]uit: ")
+		tm.Flush()
+
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			break
+		}
+
+		keys := []rune(line)
+		for i := 0; i < len(keys); i++ {
+			switch keys[i] {
+			case 'h':
+				cursor.x = max(cursor.x-1, 0)
+			case 'l':
+				cursor.x = min(cursor.x+1, m.width-1)
+			case 'k':
+				cursor.y = max(cursor.y-1, 0)
+			case 'j':
+				cursor.y = min(cursor.y+1, m.height-1)
+			case 'f':
+				if i+1 < len(keys) && keys[i+1] != '\n' && keys[i+1] != '.' {
+					i++
+					frequency = keys[i]
+				}
+			case '+':
+				m.PlaceAntenna(frequency, cursor)
+			case '-':
+				m.RemoveAntenna(cursor)
+			case 'q':
+				fmt.Println()
+				return
+			}
+		}
+	}
+	fmt.Println()
+}
@@ -6,33 +6,70 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"slices"
 
 	tm "github.com/buger/goterm"
 )
 
 var (
-	debug = flag.Bool("debug", true, "show map")
-	full  = flag.Bool("full", true, "full set of antinodes")
+	debug       = flag.Bool("debug", false, "show map")
+	interactive = flag.Bool("interactive", false, "move a cursor around the map to place and remove antennas")
+	full        = flag.Bool("full", true, "full set of antinodes")
+	summary     = flag.Bool("summary", false, "show antinodes per frequency and overlaps between them")
+	freq        = flag.String("freq", "", "show each antinode of this frequency and the antennas causing it")
+	torus       = flag.Bool("torus", false, "the map wraps around at the edges")
+	margin      = flag.Int("margin", 0, "also find antinodes up to this far off the map")
+	exact       = flag.Bool("exact", false, "with -full, every grid point on the line rather than steps of the antenna spacing")
 )
 
 type Pos struct {
 	x, y int
 }
 
+func (p Pos) Less(q Pos) bool {
+	return p.y < q.y || (p.y == q.y && p.x < q.x)
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d,%d", p.x, p.y)
+}
+
+// Pair is two antennas with the same frequency, in reading order.
+type Pair [2]Pos
+
+func NewPair(p0, p1 Pos) Pair {
+	if p1.Less(p0) {
+		p0, p1 = p1, p0
+	}
+	return Pair{p0, p1}
+}
+
 type Map struct {
 	width, height int
 	antennas      map[rune][]Pos
 	antinodes     map[Pos]bool
+	sources       map[rune]map[Pos][]Pair // frequency -> antinode -> pairs
+
+	torus  bool // the edges of the map wrap around
+	margin int  // how far off the map to look for antinodes
+	full   bool // see FindAntinodes
+	exact  bool
 }
 
 func NewMap() *Map {
 	return &Map{
 		antennas:  make(map[rune][]Pos),
 		antinodes: make(map[Pos]bool),
+		sources:   make(map[rune]map[Pos][]Pair),
 	}
 }
 
 func (m *Map) Print() {
+	m.print(nil)
+}
+
+// print draws the map, highlighting the cursor if there is one.
+func (m *Map) print(cursor *Pos) {
 	tm.Clear()
 
 	for y := 0; y < m.height; y++ {
@@ -57,50 +94,118 @@ func (m *Map) Print() {
 		}
 	}
 
+	if cursor != nil {
+		tm.MoveCursor(cursor.x*2+1, cursor.y+1)
+		cell := "."
+		if f, ok := m.AntennaAt(*cursor); ok {
+			cell = string(f)
+		}
+		tm.Print(tm.Background(tm.Color(cell, tm.BLACK), tm.CYAN))
+	}
+
 	tm.MoveCursor(1, m.height+1)
 	tm.Println()
 
 	tm.Flush()
 }
 
-func (m *Map) FindAntinodes(full bool) {
-	for _, ps := range m.antennas {
-		for _, p0 := range ps {
-			for _, p1 := range ps {
-				if p0 == p1 {
-					continue
-				}
-				dx := p1.x - p0.x
-				dy := p1.y - p0.y
-
-				if full {
-					i, j := 0, 0
-					for m.AddAntinode(p0.x-i, p0.y-j) {
-						i += dx
-						j += dy
-					}
-					i, j = 0, 0
-					for m.AddAntinode(p1.x+i, p1.y+j) {
-						i += dx
-						j += dy
-					}
-				} else {
-					m.AddAntinode(p0.x-dx, p0.y-dy)
-					m.AddAntinode(p1.x+dx, p1.y+dy)
-				}
+// FindAntinodes marks the antinodes for every pair of antennas with the same
+// frequency. Without full, that's the points twice as far from one antenna as
+// the other. With full, it's every point in line with them at a multiple of
+// their spacing, as in the puzzle. With exact too, the spacing is divided by
+// the gcd of its x and y, so every grid point exactly on the line is marked.
+func (m *Map) FindAntinodes(full, exact bool) {
+	m.full, m.exact = full, exact
+	for f, ps := range m.antennas {
+		for i, p0 := range ps {
+			for _, p1 := range ps[i+1:] {
+				m.addPair(f, p0, p1)
 			}
 		}
 	}
 }
 
-func (m *Map) AddAntinode(x, y int) bool {
-	if x < 0 || x >= m.width || y < 0 || y >= m.height {
+// addPair marks the antinodes caused by the antennas at p0 and p1, which both
+// have frequency f.
+func (m *Map) addPair(f rune, p0, p1 Pos) {
+	dx := p1.x - p0.x
+	dy := p1.y - p0.y
+
+	pair := NewPair(p0, p1)
+	add := func(x, y int) bool {
+		return m.AddAntinode(x, y, f, pair)
+	}
+
+	if m.full && m.exact {
+		g := gcd(dx, dy)
+		dx, dy = dx/g, dy/g
+
+		// Walk both ways from p0, which passes through p1
+		m.walk(p0.x, p0.y, -dx, -dy, add)
+		m.walk(p0.x+dx, p0.y+dy, dx, dy, add)
+	} else if m.full {
+		m.walk(p0.x, p0.y, -dx, -dy, add)
+		m.walk(p1.x, p1.y, dx, dy, add)
+	} else {
+		add(p0.x-dx, p0.y-dy)
+		add(p1.x+dx, p1.y+dy)
+	}
+}
+
+// walk adds antinodes from x, y onwards in steps of dx, dy until they're off
+// the map or, on a torus, back where they started.
+func (m *Map) walk(x, y, dx, dy int, add func(x, y int) bool) {
+	start := m.wrap(Pos{x, y})
+	for i, j := 0, 0; add(x+i, y+j); i, j = i+dx, j+dy {
+		if m.torus && (i != 0 || j != 0) && m.wrap(Pos{x + i, y + j}) == start {
+			return
+		}
+	}
+}
+
+// wrap returns where p is on a torus, or p itself otherwise.
+func (m *Map) wrap(p Pos) Pos {
+	if !m.torus {
+		return p
+	}
+	return Pos{
+		((p.x % m.width) + m.width) % m.width,
+		((p.y % m.height) + m.height) % m.height,
+	}
+}
+
+// Inside reports whether p is on the map itself, rather than in the margin.
+func (m *Map) Inside(p Pos) bool {
+	return p.x >= 0 && p.x < m.width && p.y >= 0 && p.y < m.height
+}
+
+// AddAntinode marks an antinode at x, y caused by the pair of antennas with
+// frequency f. On a torus, x, y wraps around the edges. Otherwise it returns
+// false if x, y is further off the map than the margin.
+func (m *Map) AddAntinode(x, y int, f rune, source Pair) bool {
+	if !m.torus && (x < -m.margin || x >= m.width+m.margin || y < -m.margin || y >= m.height+m.margin) {
 		return false
 	}
-	m.antinodes[Pos{x, y}] = true
+	p := m.wrap(Pos{x, y})
+	m.antinodes[p] = true
+
+	if m.sources[f] == nil {
+		m.sources[f] = make(map[Pos][]Pair)
+	}
+	if !slices.Contains(m.sources[f][p], source) {
+		m.sources[f][p] = append(m.sources[f][p], source)
+	}
 	return true
 }
 
+func gcd(a, b int) int {
+	a, b = max(a, -a), max(b, -b)
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
 func get[T any](v T, err error) T {
 	if err != nil {
 		log.Fatal(err)
@@ -135,12 +240,37 @@ func main() {
 		y++
 	}
 	m.height = y
+	m.torus = *torus
+	m.margin = *margin
+	if m.torus && m.margin > 0 {
+		log.Fatal("a torus has no margin")
+	}
 
-	m.FindAntinodes(*full)
+	m.FindAntinodes(*full, *exact)
+
+	if *interactive {
+		m.Interact(os.Stdin)
+	}
 
 	if *debug {
 		m.Print()
 	}
 
 	fmt.Printf("\nantinodes: %d\n", len(m.antinodes))
+	if m.margin > 0 {
+		outside := 0
+		for p := range m.antinodes {
+			if !m.Inside(p) {
+				outside++
+			}
+		}
+		fmt.Printf("off the map: %d\n", outside)
+	}
+
+	if *summary {
+		m.PrintSummary()
+	}
+	if *freq != "" {
+		m.PrintFrequency([]rune(*freq)[0])
+	}
 }
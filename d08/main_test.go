@@ -0,0 +1,209 @@
+package main
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestMap_FindAntinodes(t *testing.T) {
+	testcases := []struct {
+		name     string
+		antennas []Pos
+		full     bool
+		exact    bool
+		want     int
+	}{
+		{"pair", []Pos{{2, 2}, {3, 3}}, false, false, 2},
+		{"full", []Pos{{0, 0}, {2, 2}}, true, false, 3},
+		{"exact", []Pos{{0, 0}, {2, 2}}, true, true, 6},
+		{"exact coprime", []Pos{{0, 0}, {1, 2}}, true, true, 3},
+		{"exact ignored", []Pos{{2, 2}, {3, 3}}, false, true, 2},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMap()
+			m.width, m.height = 6, 6
+			m.antennas['a'] = tc.antennas
+
+			m.FindAntinodes(tc.full, tc.exact)
+
+			if got, want := len(m.antinodes), tc.want; got != want {
+				t.Errorf("wrong result. got = %d, want = %d", got, want)
+			}
+		})
+	}
+}
+
+func TestMap_FindAntinodesTorus(t *testing.T) {
+	testcases := []struct {
+		name          string
+		width, height int
+		antennas      []Pos
+		full          bool
+		want          int
+	}{
+		{"pair", 5, 1, []Pos{{0, 0}, {1, 0}}, false, 2},
+		{"pair wraps", 5, 1, []Pos{{0, 0}, {3, 0}}, false, 2},
+		{"row", 5, 1, []Pos{{0, 0}, {1, 0}}, true, 5},
+		{"step divides width", 6, 1, []Pos{{0, 0}, {2, 0}}, true, 3},
+		{"step doesn't divide width", 5, 1, []Pos{{0, 0}, {2, 0}}, true, 5},
+		{"diagonal", 4, 6, []Pos{{0, 0}, {1, 1}}, true, 12},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMap()
+			m.width, m.height = tc.width, tc.height
+			m.torus = true
+			m.antennas['a'] = tc.antennas
+
+			m.FindAntinodes(tc.full, false)
+
+			if got, want := len(m.antinodes), tc.want; got != want {
+				t.Errorf("wrong result. got = %d, want = %d", got, want)
+			}
+			for p := range m.antinodes {
+				if !m.Inside(p) {
+					t.Errorf("antinode %v off the map", p)
+				}
+			}
+		})
+	}
+}
+
+func TestMap_FindAntinodesMargin(t *testing.T) {
+	testcases := []struct {
+		name    string
+		margin  int
+		full    bool
+		want    int
+		outside int
+	}{
+		{"no margin", 0, false, 1, 0},
+		{"margin", 1, false, 2, 1},
+		{"full no margin", 0, true, 3, 0},
+		{"full margin", 2, true, 7, 4},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewMap()
+			m.width, m.height = 3, 3
+			m.margin = tc.margin
+			m.antennas['a'] = []Pos{{0, 0}, {1, 1}}
+
+			m.FindAntinodes(tc.full, false)
+
+			if got, want := len(m.antinodes), tc.want; got != want {
+				t.Errorf("wrong result. got = %d, want = %d", got, want)
+			}
+			outside := 0
+			for p := range m.antinodes {
+				if !m.Inside(p) {
+					outside++
+				}
+			}
+			if got, want := outside, tc.outside; got != want {
+				t.Errorf("wrong outside. got = %d, want = %d", got, want)
+			}
+		})
+	}
+}
+
+func TestMap_PlaceAntenna(t *testing.T) {
+	type edit struct {
+		f rune // 0 removes the antenna at p
+		p Pos
+	}
+	testcases := []struct {
+		name  string
+		edits []edit
+	}{
+		{"place", []edit{{'a', Pos{1, 1}}, {'a', Pos{2, 3}}, {'b', Pos{4, 4}}, {'a', Pos{0, 5}}}},
+		{"remove", []edit{{'a', Pos{1, 1}}, {'a', Pos{2, 3}}, {'a', Pos{3, 5}}, {0, Pos{2, 3}}}},
+		{"remove missing", []edit{{'a', Pos{1, 1}}, {'a', Pos{2, 3}}, {0, Pos{4, 4}}}},
+		{"replace", []edit{{'a', Pos{1, 1}}, {'a', Pos{2, 2}}, {'b', Pos{3, 3}}, {'b', Pos{2, 2}}}},
+		{"shared antinode", []edit{{'a', Pos{0, 0}}, {'a', Pos{1, 1}}, {'b', Pos{2, 0}}, {'b', Pos{2, 1}}, {0, Pos{0, 0}}}},
+		{"remove all", []edit{{'a', Pos{1, 1}}, {'a', Pos{2, 3}}, {0, Pos{1, 1}}, {0, Pos{2, 3}}}},
+	}
+	modes := []struct {
+		name               string
+		full, exact, torus bool
+	}{
+		{"pairs", false, false, false},
+		{"full", true, false, false},
+		{"exact", true, true, false},
+		{"torus", true, false, true},
+	}
+
+	for _, tc := range testcases {
+		for _, mode := range modes {
+			t.Run(tc.name+" "+mode.name, func(t *testing.T) {
+				newMap := func() *Map {
+					m := NewMap()
+					m.width, m.height = 6, 6
+					m.torus = mode.torus
+					return m
+				}
+
+				m := newMap()
+				m.FindAntinodes(mode.full, mode.exact)
+				for _, e := range tc.edits {
+					if e.f == 0 {
+						m.RemoveAntenna(e.p)
+					} else {
+						m.PlaceAntenna(e.f, e.p)
+					}
+				}
+
+				fresh := newMap()
+				for f, ps := range m.antennas {
+					fresh.antennas[f] = slices.Clone(ps)
+				}
+				fresh.FindAntinodes(mode.full, mode.exact)
+
+				if got, want := m.antinodes, fresh.antinodes; !maps.Equal(got, want) {
+					t.Errorf("wrong antinodes. got = %v, want = %v", got, want)
+				}
+				if got, want := sources(m), sources(fresh); !reflect.DeepEqual(got, want) {
+					t.Errorf("wrong sources. got = %v, want = %v", got, want)
+				}
+			})
+		}
+	}
+}
+
+// sources returns m's antinode sources with each list of pairs sorted, and
+// without frequencies which have none.
+func sources(m *Map) map[rune]map[Pos][]Pair {
+	ret := make(map[rune]map[Pos][]Pair)
+	for f, antinodes := range m.sources {
+		if len(antinodes) == 0 {
+			continue
+		}
+		ret[f] = make(map[Pos][]Pair)
+		for p, pairs := range antinodes {
+			ret[f][p] = slices.SortedFunc(slices.Values(pairs), func(a, b Pair) int {
+				if c := cmpPos(a[0], b[0]); c != 0 {
+					return c
+				}
+				return cmpPos(a[1], b[1])
+			})
+		}
+	}
+	return ret
+}
+
+func cmpPos(p, q Pos) int {
+	switch {
+	case p.Less(q):
+		return -1
+	case q.Less(p):
+		return 1
+	default:
+		return 0
+	}
+}
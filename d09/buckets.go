@@ -0,0 +1,88 @@
+package main
+
+import "container/heap"
+
+// starts is a min-heap of the start blocks of free spans.
+type starts []int
+
+func (h starts) Len() int           { return len(h) }
+func (h starts) Less(i, j int) bool { return h[i] < h[j] }
+func (h starts) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *starts) Push(x any)        { *h = append(*h, x.(int)) }
+func (h *starts) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// span is a run of blocks belonging to one file.
+type span struct {
+	id, start, size int
+}
+
+// DefragFilesBucketed does the same as DefragFiles, but keeps the free spans
+// in a min-heap of start blocks for each size. The leftmost free span a file
+// fits in is the smallest start at the top of the heaps for its size or
+// bigger, so each file is placed in O(log n) rather than by scanning the disk.
+func (m *Map) DefragFilesBucketed(cb func()) {
+	cb()
+	files, free := m.spans()
+	defrag(files, free, m.mover(cb))
+	m.rebuildFiles()
+}
+
+// defrag moves each file, right to left, into the leftmost free span it fits
+// in, calling moved before updating its start. Space freed by moving a file is
+// never reused, as it's to the right of every file left to move.
+func defrag(files, free []span, moved func(file span, to int)) {
+	var buckets []starts // size -> free span starts
+	for _, s := range free {
+		for len(buckets) <= s.size {
+			buckets = append(buckets, nil)
+		}
+		buckets[s.size] = append(buckets[s.size], s.start)
+	}
+	for i := range buckets {
+		heap.Init(&buckets[i])
+	}
+
+	// Process files right to left
+	for k := len(files) - 1; k >= 0; k-- {
+		file := files[k]
+
+		best := -1
+		for size := file.size; size < len(buckets); size++ {
+			if len(buckets[size]) == 0 || buckets[size][0] >= file.start {
+				continue
+			}
+			if best < 0 || buckets[size][0] < buckets[best][0] {
+				best = size
+			}
+		}
+		if best < 0 {
+			continue
+		}
+
+		to := heap.Pop(&buckets[best]).(int)
+		if leftover := best - file.size; leftover > 0 {
+			heap.Push(&buckets[leftover], to+file.size)
+		}
+		moved(file, to)
+		files[k].start = to
+	}
+}
+
+// rebuildFiles regroups the blocks into files after their ids have changed.
+func (m *Map) rebuildFiles() {
+	m.files = m.files[:0]
+	var file *File
+	for _, block := range m.blocks {
+		if file == nil || block.id != file.id {
+			file = &File{id: block.id}
+			m.files = append(m.files, file)
+		}
+		file.blocks = append(file.blocks, block)
+		block.file = file
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Extents is a disk held as runs of blocks rather than a Block per block, so
+// it stays small and fast for disk maps with millions of blocks. It has the
+// same results as Map, but no callbacks to show each step, as a disk that big
+// is too big to print.
+type Extents struct {
+	spans  []span // in disk order, with free space having id -1
+	length int    // blocks on the disk
+}
+
+// ParseExtents reads a map in the puzzle's format, as Parse does.
+func ParseExtents(entries string) (*Extents, error) {
+	spans, err := parseDense(entries)
+	if err != nil {
+		return nil, err
+	}
+	return newExtents(spans), nil
+}
+
+// ParseSparseExtents reads a map written by Delimited, as ParseSparse does.
+func ParseSparseExtents(blocks string) (*Extents, error) {
+	spans, err := parseSparse(blocks)
+	if err != nil {
+		return nil, err
+	}
+	return newExtents(spans), nil
+}
+
+// newExtents returns a disk holding spans, which cover it in disk order.
+func newExtents(spans []span) *Extents {
+	e := &Extents{}
+	for _, s := range spans {
+		if s.size > 0 {
+			e.spans = append(e.spans, s)
+		}
+		e.length += s.size
+	}
+	return e
+}
+
+// appendSpan appends s to spans, or grows the last span if it's right before s
+// and for the same file.
+func appendSpan(spans []span, s span) []span {
+	if n := len(spans); n > 0 && spans[n-1].id == s.id && spans[n-1].start+spans[n-1].size == s.start {
+		spans[n-1].size += s.size
+		return spans
+	}
+	return append(spans, s)
+}
+
+// Clone returns a copy of the disk, to compare against later.
+func (e *Extents) Clone() *Extents {
+	return &Extents{spans: slices.Clone(e.spans), length: e.length}
+}
+
+// runs returns the spans, joining any in a row for the same file.
+func (e *Extents) runs() []span {
+	var runs []span
+	for _, s := range e.spans {
+		runs = appendSpan(runs, s)
+	}
+	return runs
+}
+
+// split returns the files and the free spaces on the disk, in disk order.
+func (e *Extents) split() (files, free []span) {
+	for _, s := range e.spans {
+		if s.id < 0 {
+			free = append(free, s)
+		} else {
+			files = append(files, s)
+		}
+	}
+	return files, free
+}
+
+// fill replaces the disk's spans with the given files, and free space around
+// them.
+func (e *Extents) fill(files []span) {
+	slices.SortFunc(files, func(a, b span) int { return a.start - b.start })
+	e.spans = e.spans[:0]
+	at := 0
+	for _, f := range files {
+		if f.start > at {
+			e.spans = appendSpan(e.spans, span{-1, at, f.start - at})
+		}
+		e.spans = appendSpan(e.spans, f)
+		at = f.start + f.size
+	}
+	if at < e.length {
+		e.spans = appendSpan(e.spans, span{-1, at, e.length - at})
+	}
+}
+
+// Dense writes the disk as Map.Dense does.
+func (e *Extents) Dense() (string, error) {
+	return dense(e.runs())
+}
+
+// Delimited writes every block as Map.Delimited does.
+func (e *Extents) Delimited() string {
+	var s strings.Builder
+	for _, r := range e.spans {
+		id := "."
+		if r.id >= 0 {
+			id = strconv.Itoa(r.id)
+		}
+		for i := 0; i < r.size; i++ {
+			if s.Len() > 0 {
+				s.WriteString(",")
+			}
+			s.WriteString(id)
+		}
+	}
+	return s.String()
+}
+
+// DefragBlocks moves file blocks one at a time from the end of the disk into
+// the leftmost free block, as Map.DefragBlocks does, but a run at a time.
+func (e *Extents) DefragBlocks() {
+	var files []span
+	i, j := 0, len(e.spans)-1
+	for i <= j {
+		switch {
+		case e.spans[i].id >= 0:
+			files = append(files, e.spans[i])
+			i++
+		case e.spans[j].id < 0 || i == j:
+			j--
+		default:
+			n := min(e.spans[i].size, e.spans[j].size)
+			files = append(files, span{e.spans[j].id, e.spans[i].start, n})
+			e.spans[i].start += n
+			e.spans[i].size -= n
+			e.spans[j].size -= n
+			if e.spans[i].size == 0 {
+				i++
+			}
+			if e.spans[j].size == 0 {
+				j--
+			}
+		}
+	}
+	e.fill(files)
+}
+
+// DefragFiles moves whole files as Map.DefragFilesBucketed does.
+func (e *Extents) DefragFiles() {
+	files, free := e.split()
+	defrag(files, free, func(span, int) {})
+	e.fill(files)
+}
+
+// Compact moves whole files as Map.Compact does.
+func (e *Extents) Compact(strategy Strategy) {
+	files, free := e.split()
+	compact(files, free, strategy, func(span, int) {})
+	e.fill(files)
+}
+
+// Checksum is the sum of each file block's position times its file id, as
+// Map.Checksum, but summing each run at once.
+func (e *Extents) Checksum() int {
+	sum := 0
+	for _, s := range e.spans {
+		if s.id < 0 {
+			continue
+		}
+		// start + (start+1) + ... + (start+size-1)
+		sum += s.id * (s.size*s.start + s.size*(s.size-1)/2)
+	}
+	return sum
+}
+
+// Metrics measures the disk as Map.Metrics does, comparing it with before.
+func (e *Extents) Metrics(before *Extents) Metrics {
+	metrics := measure(e.runs())
+
+	// Both disks are covered by their spans, so walk them at once, a piece at
+	// a time where neither changes file
+	at := 0
+	for i, j := 0, 0; i < len(e.spans) && j < len(before.spans); {
+		a, b := e.spans[i], before.spans[j]
+		end := min(a.start+a.size, b.start+b.size)
+		if a.id >= 0 && a.id != b.id {
+			metrics.Moved += end - at
+		}
+		at = end
+		if end == a.start+a.size {
+			i++
+		}
+		if end == b.start+b.size {
+			j++
+		}
+	}
+	return metrics
+}
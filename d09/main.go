@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"slices"
 	"strconv"
@@ -12,8 +13,12 @@ import (
 )
 
 var (
-	debug = flag.Bool("debug", false, "debug mode")
-	split = flag.Bool("split", false, "split files during defrag")
+	debug    = flag.Bool("debug", false, "debug mode")
+	split    = flag.Bool("split", false, "split files during defrag")
+	naive    = flag.Bool("naive", false, "defrag files by scanning for free space rather than using buckets")
+	strategy = flag.String("strategy", "", "compact files with the first, best, worst or next fit strategy, or all to compare them")
+	sparse   = flag.Bool("sparse", false, "read the input as comma separated blocks, as printed after delimited:")
+	extents  = flag.Bool("extents", false, "hold the disk as runs of blocks, for very large disk maps, printing only the results")
 )
 
 type Block struct {
@@ -48,38 +53,96 @@ type Map struct {
 	blocks []*Block
 }
 
-func (m *Map) Dense() string {
+// Dense writes the map in the puzzle's format, where digits alternate between
+// the size of a file and the free space after it, and each file's id is its
+// position in that list. Missing ids are written as empty files, but the
+// format can't hold files out of order, split up, or runs of more than 9
+// blocks, so those are an error rather than a map which reads back differently.
+func (m *Map) Dense() (string, error) {
+	return dense(m.runs())
+}
+
+// dense writes runs of blocks, with no two in a row for the same file, as
+// described for Map.Dense.
+func dense(runs []span) (string, error) {
 	var s strings.Builder
-	id := m.blocks[0].id
-	count := 1
-	free := m.blocks[0].Free()
-	for _, block := range m.blocks[1:] {
-		if block.id == id {
-			count++
-		} else {
-			for count > 9 {
-				s.WriteString("90")
-				count -= 9
-			}
-			s.WriteString(strconv.Itoa(count))
-			// Two consecutive non-free blocks need an interstitial zero-sized
-			// free block.
-			if !free && !block.Free() {
-				s.WriteString("0")
+	next := 0  // id of the next file entry
+	last := -1 // id of the last file written
+	entry := func(count int) {
+		s.WriteByte(byte('0' + count))
+	}
+	for _, r := range runs {
+		if r.size > 9 {
+			return "", fmt.Errorf("run of %d blocks at block %d is longer than 9", r.size, r.start)
+		}
+
+		fileNext := s.Len()%2 == 0
+		if r.id < 0 {
+			if fileNext {
+				entry(0)
+				next++
 			}
-			id = block.id
-			count = 1
-			free = id < 0
+			entry(r.size)
+			continue
+		}
+
+		if !fileNext {
+			entry(0)
 		}
+		if r.id < next {
+			return "", fmt.Errorf("file %d at block %d comes after file %d", r.id, r.start, last)
+		}
+		for ; next < r.id; next++ {
+			entry(0)
+			entry(0)
+		}
+		entry(r.size)
+		next++
+		last = r.id
 	}
-	for count > 9 {
-		s.WriteString("90")
-		count -= 9
+	return s.String(), nil
+}
+
+// runs returns the runs of blocks for the same file, or free space, in disk
+// order.
+func (m *Map) runs() []span {
+	var runs []span
+	for i, b := range m.blocks {
+		runs = appendSpan(runs, span{b.id, i, 1})
+	}
+	return runs
+}
+
+// spans returns the files and the non-empty free spaces on the disk, in disk
+// order.
+func (m *Map) spans() (files, free []span) {
+	start := 0
+	for _, f := range m.files {
+		size := f.Size()
+		if !f.Free() {
+			files = append(files, span{f.id, start, size})
+		} else if size > 0 {
+			free = append(free, span{-1, start, size})
+		}
+		start += size
+	}
+	return files, free
+}
+
+// mover returns a function which moves the blocks of a file to start at block
+// to, then calls cb.
+func (m *Map) mover(cb func()) func(file span, to int) {
+	return func(file span, to int) {
+		for i := 0; i < file.size; i++ {
+			m.blocks[to+i].id = file.id
+			m.blocks[file.start+i].id = -1
+		}
+		cb()
 	}
-	s.WriteString(strconv.Itoa(count))
-	return s.String()
 }
 
+// Sparse writes each block as its file's id, or . if it's free. It's only
+// readable while ids are single digits; see Delimited.
 func (m *Map) Sparse() string {
 	var s strings.Builder
 	for _, block := range m.blocks {
@@ -92,6 +155,31 @@ func (m *Map) Sparse() string {
 	return s.String()
 }
 
+// Delimited is like Sparse but separates the blocks with commas, so it can be
+// read back by ParseSparse whatever the ids.
+func (m *Map) Delimited() string {
+	var s strings.Builder
+	for i, block := range m.blocks {
+		if i > 0 {
+			s.WriteString(",")
+		}
+		if block.Free() {
+			s.WriteString(".")
+		} else {
+			s.WriteString(strconv.Itoa(block.id))
+		}
+	}
+	return s.String()
+}
+
+// Equal reports whether both maps have the same blocks, ignoring how they're
+// grouped into files.
+func (m *Map) Equal(o *Map) bool {
+	return slices.EqualFunc(m.blocks, o.blocks, func(a, b *Block) bool {
+		return a.id == b.id
+	})
+}
+
 func (m *Map) SparseLimited(start, end int) string {
 	var s strings.Builder
 	for i, block := range m.blocks {
@@ -200,6 +288,128 @@ func (m *Map) Checksum() int {
 	return sum
 }
 
+func get[T any](v T, err error) T {
+	if err != nil {
+		log.Fatal(err)
+	}
+	return v
+}
+
+// Parse reads a disk map, where each digit alternates between the size of a
+// file and the size of the free space after it.
+func Parse(entries string) (*Map, error) {
+	spans, err := parseDense(entries)
+	if err != nil {
+		return nil, err
+	}
+	return newMap(spans), nil
+}
+
+// ParseSparse reads a map written by Delimited.
+func ParseSparse(blocks string) (*Map, error) {
+	spans, err := parseSparse(blocks)
+	if err != nil {
+		return nil, err
+	}
+	return newMap(spans), nil
+}
+
+// parseDense returns a span for each entry of a map in the puzzle's format,
+// including empty ones.
+func parseDense(entries string) ([]span, error) {
+	var spans []span
+	start := 0
+	for i, e := range entries {
+		if e < '0' || e > '9' {
+			return nil, fmt.Errorf("invalid entry %q", e)
+		}
+		size := int(e - '0')
+		id := -1
+		if i%2 == 0 {
+			id = i / 2
+		}
+		spans = append(spans, span{id, start, size})
+		start += size
+	}
+	return spans, nil
+}
+
+// parseSparse returns the runs of blocks in a map written by Delimited.
+func parseSparse(blocks string) ([]span, error) {
+	var spans []span
+	if blocks == "" {
+		return spans, nil
+	}
+	for i, b := range strings.Split(blocks, ",") {
+		id := -1
+		if b != "." {
+			var err error
+			id, err = strconv.Atoi(b)
+			if err != nil || id < 0 {
+				return nil, fmt.Errorf("invalid block %q", b)
+			}
+		}
+		spans = appendSpan(spans, span{id, i, 1})
+	}
+	return spans, nil
+}
+
+// newMap returns a map with a file for each span, and a block for each of its
+// blocks.
+func newMap(spans []span) *Map {
+	m := &Map{}
+	for _, s := range spans {
+		file := &File{id: s.id}
+		m.files = append(m.files, file)
+		for b := 0; b < s.size; b++ {
+			block := &Block{id: s.id, file: file}
+			m.blocks = append(m.blocks, block)
+			file.blocks = append(file.blocks, block)
+		}
+	}
+	return m
+}
+
+// Print writes the map in each format, or why it can't be written densely.
+func (m *Map) Print() {
+	dense, err := m.Dense()
+	if err != nil {
+		dense = fmt.Sprintf("(not representable: %v)", err)
+	}
+	fmt.Printf("dense: %s\n", dense)
+	fmt.Printf("sparse: %s\n", m.Sparse())
+	fmt.Printf("delimited: %s\n", m.Delimited())
+}
+
+// runExtents defrags the disk held as Extents, with the same flags as main.
+func runExtents(entries string) {
+	parse := ParseExtents
+	if *sparse {
+		parse = ParseSparseExtents
+	}
+
+	names := []string{*strategy}
+	if *strategy == "all" {
+		names = strategies
+	}
+	for _, name := range names {
+		e := get(parse(entries))
+		before := e.Clone()
+		prefix := ""
+		switch {
+		case name != "":
+			s := get(NewStrategy(name))
+			e.Compact(s)
+			prefix = s.Name() + ": "
+		case *split:
+			e.DefragBlocks()
+		default:
+			e.DefragFiles()
+		}
+		fmt.Printf("%schecksum %d, %s\n", prefix, e.Checksum(), e.Metrics(before))
+	}
+}
+
 func main() {
 	flag.Parse()
 	filename := "example.txt"
@@ -211,33 +421,47 @@ func main() {
 		panic(err)
 	}
 
-	m := &Map{}
-
 	s := bufio.NewScanner(f)
+	s.Buffer(nil, math.MaxInt32)
+	var entries string
 	for s.Scan() {
-		entries := s.Text()
-		for i, e := range entries {
-			if e < '0' || e > '9' {
-				log.Fatalf("invalid entry %q", e)
-			}
-			blocks := int(e - '0')
-			id := -1
-			if i%2 == 0 {
-				id = i / 2
-			}
-			file := &File{id: id}
-			m.files = append(m.files, file)
-			for b := 0; b < blocks; b++ {
-				block := &Block{id: id, file: file}
-				m.blocks = append(m.blocks, block)
-				file.blocks = append(file.blocks, block)
-			}
+		entries += s.Text()
+	}
+	if err := s.Err(); err != nil {
+		log.Fatal(err)
+	}
+	if *extents {
+		runExtents(entries)
+		return
+	}
+
+	parse := Parse
+	if *sparse {
+		parse = ParseSparse
+	}
+	m := get(parse(entries))
+
+	if *strategy == "all" {
+		for _, name := range strategies {
+			m := get(parse(entries))
+			s := get(NewStrategy(name))
+			before := m.IDs()
+			m.Compact(s, func() {})
+			fmt.Printf("%s: checksum %d, %s\n", s.Name(), m.Checksum(), m.Metrics(before))
 		}
+		return
 	}
-	fmt.Printf("dense: %s\n", m.Dense())
-	fmt.Printf("sparse: %s\n", m.Sparse())
+
+	m.Print()
 	fmt.Printf("defragging...\n")
-	if *split {
+	before := m.IDs()
+	if *strategy != "" {
+		m.Compact(get(NewStrategy(*strategy)), func() {
+			if *debug {
+				fmt.Println(m.Sparse())
+			}
+		})
+	} else if *split {
 		m.DefragBlocks(func(i, j int) {
 			if *debug {
 				if j-i < 20 {
@@ -247,13 +471,17 @@ func main() {
 			}
 		})
 	} else {
-		m.DefragFiles(func() {
+		defrag := m.DefragFilesBucketed
+		if *naive {
+			defrag = m.DefragFiles
+		}
+		defrag(func() {
 			if *debug {
 				fmt.Println(m.Sparse())
 			}
 		})
 	}
-	fmt.Printf("dense: %s\n", m.Dense())
-	fmt.Printf("sparse: %s\n", m.Sparse())
+	m.Print()
 	fmt.Printf("checksum: %d\n", m.Checksum())
+	fmt.Printf("%s\n", m.Metrics(before))
 }
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// generate returns a random disk map with the given number of files.
+func generate(seed int64, files int) string {
+	r := rand.New(rand.NewSource(seed))
+	var b strings.Builder
+	for i := 0; i < files; i++ {
+		b.WriteByte(byte('1' + r.Intn(9)))
+		if i < files-1 {
+			b.WriteByte(byte('0' + r.Intn(10)))
+		}
+	}
+	return b.String()
+}
+
+func TestMap_DefragFilesBucketed(t *testing.T) {
+	testcases := []struct {
+		name    string
+		entries string
+	}{
+		{"example", "2333133121414131402"},
+		{"single", "5"},
+		{"no free", "10203"},
+		{"random", generate(1, 100)},
+		{"large", generate(2, 2000)},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			naive, err := Parse(tc.entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			naive.DefragFiles(func() {})
+
+			bucketed, err := Parse(tc.entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bucketed.DefragFilesBucketed(func() {})
+
+			if got, want := bucketed.Delimited(), naive.Delimited(); got != want {
+				t.Errorf("wrong result. got = %s, want = %s", got, want)
+			}
+			if got, want := bucketed.Checksum(), naive.Checksum(); got != want {
+				t.Errorf("wrong checksum. got = %d, want = %d", got, want)
+			}
+		})
+	}
+}
+
+func TestMap_Compact(t *testing.T) {
+	entries := generate(4, 500)
+	naive, err := Parse(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	naive.DefragFiles(func() {})
+
+	for _, name := range strategies {
+		t.Run(name, func(t *testing.T) {
+			m, err := Parse(entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			strategy, err := NewStrategy(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := m.IDs()
+			m.Compact(strategy, func() {})
+
+			metrics := m.Metrics(before)
+			if got, want := metrics.Fragmentation, 1.0; got != want {
+				t.Errorf("wrong fragmentation. got = %f, want = %f", got, want)
+			}
+			if name == "first" {
+				if got, want := m.Checksum(), naive.Checksum(); got != want {
+					t.Errorf("wrong checksum. got = %d, want = %d", got, want)
+				}
+			}
+		})
+	}
+}
+
+// randomMap returns a map of about n blocks whose runs are at most 9 long. With
+// ordered, each file is a single run and the ids increase along the disk, with
+// some skipped, so it can be written densely.
+func randomMap(seed int64, n int, ordered bool) *Map {
+	r := rand.New(rand.NewSource(seed))
+	m := &Map{}
+	id, last := 0, -1
+	for len(m.blocks) < n {
+		if r.Intn(3) == 0 {
+			id = -1
+			if len(m.blocks) == 0 {
+				// Free space first takes up file 0's entry
+				last = 0
+			}
+		} else if ordered {
+			id = last + 1 + r.Intn(3)
+			last = id
+		} else {
+			id = r.Intn(n)
+		}
+		for range 1 + r.Intn(9) {
+			m.blocks = append(m.blocks, &Block{id: id})
+		}
+		if id < 0 {
+			// Free space in the next run would make this one longer than 9
+			last++
+			m.blocks = append(m.blocks, &Block{id: last})
+		}
+	}
+	m.rebuildFiles()
+	return m
+}
+
+func TestMap_Dense(t *testing.T) {
+	for seed := range int64(100) {
+		m := randomMap(seed, 200, true)
+		dense, err := m.Dense()
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		got, err := Parse(dense)
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		if !got.Equal(m) {
+			t.Errorf("seed %d: wrong result. got = %s, want = %s", seed, got.Delimited(), m.Delimited())
+		}
+	}
+
+	for seed := range int64(100) {
+		entries := generate(seed, 100)
+		m, err := Parse(entries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := m.Dense(); err != nil || got != entries {
+			t.Errorf("seed %d: wrong result. got = %s, %v, want = %s", seed, got, err, entries)
+		}
+	}
+}
+
+func TestMap_DenseUnrepresentable(t *testing.T) {
+	testcases := []struct {
+		name   string
+		blocks string
+	}{
+		{"out of order", "0,2,1"},
+		{"split file", "0,1,0"},
+		{"long file", "0,0,0,0,0,0,0,0,0,0"},
+		{"long free", ".,.,.,.,.,.,.,.,.,.,0"},
+		{"first file after free", ".,0"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := ParseSparse(tc.blocks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dense, err := m.Dense(); err == nil {
+				t.Errorf("wrong result. got = %s, want error", dense)
+			}
+		})
+	}
+}
+
+func TestParseSparse(t *testing.T) {
+	for seed := range int64(100) {
+		m := randomMap(seed, 200, false)
+		m.DefragFilesBucketed(func() {})
+		got, err := ParseSparse(m.Delimited())
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		if !got.Equal(m) {
+			t.Errorf("seed %d: wrong result. got = %s, want = %s", seed, got.Delimited(), m.Delimited())
+		}
+	}
+}
+
+func TestStrategy_Fit(t *testing.T) {
+	// A small gap before a big one and a middling one
+	free := []span{{-1, 0, 1}, {-1, 3, 3}, {-1, 8, 2}}
+	testcases := []struct {
+		name     string
+		strategy Strategy
+		size     int
+		want     int
+		wantLast int // for next fit, where it should look from next time
+	}{
+		{"first", firstFit{}, 2, 1, 0},
+		{"first exact", firstFit{}, 1, 0, 0},
+		{"first none", firstFit{}, 4, -1, 0},
+		{"best", bestFit{}, 2, 2, 0},
+		{"best exact", bestFit{}, 3, 1, 0},
+		{"best none", bestFit{}, 4, -1, 0},
+		{"worst", worstFit{}, 1, 1, 0},
+		{"worst none", worstFit{}, 4, -1, 0},
+		{"next from start", &nextFit{}, 2, 1, 3},
+		{"next from last", &nextFit{last: 8}, 1, 2, 8},
+		{"next between", &nextFit{last: 5}, 1, 2, 8},
+		{"next wraps", &nextFit{last: 8}, 3, 1, 3},
+		{"next wraps to start", &nextFit{last: 9}, 1, 0, 0},
+		{"next none", &nextFit{last: 3}, 4, -1, 3},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			file := span{7, 20, tc.size}
+			if got, want := tc.strategy.Fit(free, file), tc.want; got != want {
+				t.Errorf("wrong result. got = %d, want = %d", got, want)
+			}
+			if n, ok := tc.strategy.(*nextFit); ok {
+				if got, want := n.last, tc.wantLast; got != want {
+					t.Errorf("wrong last. got = %d, want = %d", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMap_CompactMetrics(t *testing.T) {
+	// 0...1..22: file 2 fits in either free span, and file 1 only in the
+	// first
+	const entries = "13122"
+	testcases := []struct {
+		strategy string
+		want     string
+		metrics  Metrics
+	}{
+		{"first", "0221.....", Metrics{FreeExtents: 1, LargestFree: 5, Fragmentation: 1, Moved: 3}},
+		{"best", "01...22..", Metrics{FreeExtents: 2, LargestFree: 3, Fragmentation: 1, Moved: 3}},
+		{"worst", "0221.....", Metrics{FreeExtents: 1, LargestFree: 5, Fragmentation: 1, Moved: 3}},
+		{"next", "0221.....", Metrics{FreeExtents: 1, LargestFree: 5, Fragmentation: 1, Moved: 3}},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.strategy, func(t *testing.T) {
+			m, err := Parse(entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			strategy, err := NewStrategy(tc.strategy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := m.IDs()
+			m.Compact(strategy, func() {})
+
+			if got, want := m.Sparse(), tc.want; got != want {
+				t.Errorf("wrong result. got = %s, want = %s", got, want)
+			}
+			if got, want := m.Metrics(before), tc.metrics; got != want {
+				t.Errorf("wrong metrics. got = %s, want = %s", got, want)
+			}
+		})
+	}
+}
+
+func benchmarkDefrag(b *testing.B, files int, defrag func(m *Map) func(func())) {
+	entries := generate(3, files)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		m, err := Parse(entries)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		defrag(m)(func() {})
+	}
+}
+
+func BenchmarkMap_DefragFiles(b *testing.B) {
+	benchmarkDefrag(b, 20000, func(m *Map) func(func()) { return m.DefragFiles })
+}
+
+func BenchmarkMap_DefragFilesBucketed(b *testing.B) {
+	benchmarkDefrag(b, 20000, func(m *Map) func(func()) { return m.DefragFilesBucketed })
+}
+
+func TestExtents(t *testing.T) {
+	type operation struct {
+		name      string
+		onMap     func(m *Map)
+		onExtents func(e *Extents)
+	}
+	operations := []operation{
+		{"none", func(m *Map) {}, func(e *Extents) {}},
+		{"blocks", func(m *Map) { m.DefragBlocks(func(i, j int) {}) }, (*Extents).DefragBlocks},
+		{"files", func(m *Map) { m.DefragFiles(func() {}) }, (*Extents).DefragFiles},
+	}
+	for _, name := range strategies {
+		operations = append(operations, operation{
+			name,
+			func(m *Map) { m.Compact(get(NewStrategy(name)), func() {}) },
+			func(e *Extents) { e.Compact(get(NewStrategy(name))) },
+		})
+	}
+
+	testcases := []struct {
+		name   string
+		sparse bool
+		input  string
+	}{
+		{"example", false, "2333133121414131402"},
+		{"single", false, "5"},
+		{"no free", false, "10203"},
+		{"random", false, generate(5, 300)},
+		{"unordered", true, randomMap(6, 1000, false).Delimited()},
+	}
+
+	for _, tc := range testcases {
+		for _, op := range operations {
+			t.Run(tc.name+"/"+op.name, func(t *testing.T) {
+				parse, parseExtents := Parse, ParseExtents
+				if tc.sparse {
+					parse, parseExtents = ParseSparse, ParseSparseExtents
+				}
+				m, err := parse(tc.input)
+				if err != nil {
+					t.Fatal(err)
+				}
+				e, err := parseExtents(tc.input)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				before, beforeExtents := m.IDs(), e.Clone()
+				op.onMap(m)
+				op.onExtents(e)
+
+				if got, want := e.Delimited(), m.Delimited(); got != want {
+					t.Errorf("wrong result. got = %s, want = %s", got, want)
+				}
+				if got, want := e.Checksum(), m.Checksum(); got != want {
+					t.Errorf("wrong checksum. got = %d, want = %d", got, want)
+				}
+				if got, want := e.Metrics(beforeExtents), m.Metrics(before); got != want {
+					t.Errorf("wrong metrics. got = %s, want = %s", got, want)
+				}
+				got, gotErr := e.Dense()
+				want, wantErr := m.Dense()
+				if got != want || (gotErr == nil) != (wantErr == nil) {
+					t.Errorf("wrong dense. got = %s, %v, want = %s, %v", got, gotErr, want, wantErr)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkExtents_DefragFiles(b *testing.B) {
+	entries := generate(3, 20000)
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		e, err := ParseExtents(entries)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		e.DefragFiles()
+	}
+}
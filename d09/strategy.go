@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Strategy picks which free span each file is moved into when compacting.
+type Strategy interface {
+	Name() string
+
+	// Fit returns the index of the span in free to move file into, or -1 to
+	// leave the file where it is. The spans are in disk order, and are all
+	// to the left of the file.
+	Fit(free []span, file span) int
+}
+
+func NewStrategy(name string) (Strategy, error) {
+	switch name {
+	case "first":
+		return firstFit{}, nil
+	case "best":
+		return bestFit{}, nil
+	case "worst":
+		return worstFit{}, nil
+	case "next":
+		return &nextFit{}, nil
+	default:
+		return nil, fmt.Errorf("unknown strategy %q", name)
+	}
+}
+
+var strategies = []string{"first", "best", "worst", "next"}
+
+// firstFit moves each file into the leftmost span big enough for it, as in
+// the puzzle.
+type firstFit struct{}
+
+func (firstFit) Name() string { return "first-fit" }
+
+func (firstFit) Fit(free []span, file span) int {
+	for i, s := range free {
+		if s.size >= file.size {
+			return i
+		}
+	}
+	return -1
+}
+
+// bestFit moves each file into the smallest span big enough for it.
+type bestFit struct{}
+
+func (bestFit) Name() string { return "best-fit" }
+
+func (bestFit) Fit(free []span, file span) int {
+	best := -1
+	for i, s := range free {
+		if s.size >= file.size && (best < 0 || s.size < free[best].size) {
+			best = i
+		}
+	}
+	return best
+}
+
+// worstFit moves each file into the biggest span, if it fits.
+type worstFit struct{}
+
+func (worstFit) Name() string { return "worst-fit" }
+
+func (worstFit) Fit(free []span, file span) int {
+	worst := -1
+	for i, s := range free {
+		if s.size >= file.size && (worst < 0 || s.size > free[worst].size) {
+			worst = i
+		}
+	}
+	return worst
+}
+
+// nextFit is like firstFit, but starts looking where it last moved a file to,
+// wrapping around to the start of the disk.
+type nextFit struct {
+	last int // block the last file was moved to
+}
+
+func (*nextFit) Name() string { return "next-fit" }
+
+func (n *nextFit) Fit(free []span, file span) int {
+	from := sort.Search(len(free), func(i int) bool { return free[i].start >= n.last })
+	for k := range free {
+		i := (from + k) % len(free)
+		if free[i].size >= file.size {
+			n.last = free[i].start
+			return i
+		}
+	}
+	return -1
+}
+
+// Compact moves whole files left into free spans chosen by the strategy,
+// trying each file once from right to left.
+func (m *Map) Compact(strategy Strategy, cb func()) {
+	cb()
+	files, free := m.spans()
+	compact(files, free, strategy, m.mover(cb))
+	m.rebuildFiles()
+}
+
+// compact moves files as described for Map.Compact, calling moved before
+// updating each file's start.
+func compact(files, free []span, strategy Strategy, moved func(file span, to int)) {
+	for k := len(files) - 1; k >= 0; k-- {
+		file := files[k]
+		left := sort.Search(len(free), func(i int) bool { return free[i].start >= file.start })
+		i := strategy.Fit(free[:left], file)
+		if i < 0 {
+			continue
+		}
+
+		moved(file, free[i].start)
+		files[k].start = free[i].start
+		free[i].start += file.size
+		free[i].size -= file.size
+		if free[i].size == 0 {
+			free = append(free[:i], free[i+1:]...)
+		}
+	}
+}
+
+// Metrics describe how fragmented a disk is.
+type Metrics struct {
+	FreeExtents   int     // runs of free blocks
+	LargestFree   int     // blocks in the longest run of free blocks
+	Fragmentation float64 // average number of runs of blocks per file
+	Moved         int     // blocks holding a different file than before
+}
+
+func (m Metrics) String() string {
+	return fmt.Sprintf("free extents: %d, largest free extent: %d, file fragmentation: %.3f, blocks moved: %d",
+		m.FreeExtents, m.LargestFree, m.Fragmentation, m.Moved)
+}
+
+// IDs returns the file id of each block, to compare against later.
+func (m *Map) IDs() []int {
+	ids := make([]int, len(m.blocks))
+	for i, b := range m.blocks {
+		ids[i] = b.id
+	}
+	return ids
+}
+
+// Metrics measures the disk's fragmentation, and how many file blocks have
+// moved since it had the given ids.
+func (m *Map) Metrics(before []int) Metrics {
+	metrics := measure(m.runs())
+	for i, b := range m.blocks {
+		if !b.Free() && i < len(before) && before[i] != b.id {
+			metrics.Moved++
+		}
+	}
+	return metrics
+}
+
+// measure returns the metrics for runs of blocks, with no two in a row for the
+// same file, apart from how many have moved.
+func measure(runs []span) Metrics {
+	var metrics Metrics
+	count := make(map[int]int) // file id -> runs of blocks
+	for _, r := range runs {
+		if r.id < 0 {
+			metrics.FreeExtents++
+			metrics.LargestFree = max(metrics.LargestFree, r.size)
+		} else {
+			count[r.id]++
+		}
+	}
+
+	total := 0
+	for _, n := range count {
+		total += n
+	}
+	if len(count) > 0 {
+		metrics.Fragmentation = float64(total) / float64(len(count))
+	}
+	return metrics
+}
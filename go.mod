@@ -5,10 +5,10 @@ go 1.23.3
 require (
 	github.com/buger/goterm v1.0.4
 	github.com/fatih/color v1.18.0
+	github.com/mattn/go-isatty v0.0.20
 )
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
 	golang.org/x/sys v0.25.0 // indirect
 )